@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/audit"
+)
+
+// runAuditCommand handles the "audit" subcommand and returns the exit code
+func runAuditCommand(settings config.Settings, args []string) int {
+	if len(args) == 0 {
+		auditUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "verify":
+		return auditVerify(settings, args[1:])
+	case "path":
+		fmt.Println(settings.AuditLog)
+		return 0
+	case "help", "-h", "--help":
+		auditUsage()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown audit command: %s\n", args[0])
+		auditUsage()
+		return 2
+	}
+}
+
+func auditUsage() {
+	fmt.Println("Usage: australian-business-data-api audit <command> [options]")
+	fmt.Println("Every command accepts --profile <name> to use a configuration profile (default $ABN_PROFILE or the config file's profile)")
+	fmt.Println("Commands:")
+	fmt.Println("  verify [--file <path>] [--expect-head <hash>]")
+	fmt.Println("                           Check that no audit entry has been changed, removed or reordered")
+	fmt.Println("  path                     Print the audit log location")
+}
+
+func auditVerify(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	flagFile := fs.String("file", settings.AuditLog, "Audit log to verify")
+	flagExpectHead := fs.String("expect-head", "", "Head hash printed by an earlier verify, which must still be in the log")
+	fs.Parse(args)
+
+	if *flagFile == "" {
+		fmt.Fprintln(os.Stderr, "Auditing is disabled; set audit_log or pass --file")
+		return 2
+	}
+
+	summary, err := audit.Verify(*flagFile, *flagExpectHead)
+	if err != nil {
+		var verifyErr *audit.VerifyError
+		if errors.As(err, &verifyErr) {
+			logger.Logger.Error("Audit log verification failed", "path", *flagFile, "line", verifyErr.Line, "valid_entries", summary.Entries, "reason", verifyErr.Reason)
+			fmt.Printf("FAILED: %s (%d valid entries before it)\n", err, summary.Entries)
+			return 1
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	logger.Logger.Info("Audit log verified", "path", *flagFile, "entries", summary.Entries, "head", summary.Head)
+	fmt.Printf("OK: %d entries verified in %s\n", summary.Entries, *flagFile)
+	if summary.Head != "" {
+		fmt.Printf("Head: %s\n", summary.Head)
+		fmt.Println("Keep the head somewhere else and pass it to --expect-head later to detect entries removed from the end")
+	}
+	return 0
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/cache"
+)
+
+// runCacheCommand handles the "cache" subcommand and returns the exit code
+func runCacheCommand(settings config.Settings, args []string) int {
+	if len(args) == 0 {
+		cacheUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		return cacheList(settings, args[1:])
+	case "show":
+		return cacheShow(settings, args[1:])
+	case "purge":
+		return cachePurge(settings, args[1:])
+	case "export":
+		return cacheExport(settings, args[1:])
+	case "import":
+		return cacheImport(settings, args[1:])
+	case "warm":
+		return cacheWarm(settings, args[1:])
+	case "help", "-h", "--help":
+		cacheUsage()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown cache command: %s\n", args[0])
+		cacheUsage()
+		return 2
+	}
+}
+
+func cacheUsage() {
+	fmt.Println("Usage: australian-business-data-api cache <command> [options]")
+	fmt.Println("Every command accepts --profile <name> to use a configuration profile (default $ABN_PROFILE or the config file's profile)")
+	fmt.Println("and --dataset <name> to use the business-names (default) or companies cache")
+	fmt.Println("Commands:")
+	fmt.Println("  list                   List cached queries")
+	fmt.Println("  show <key>             Show a cached entry")
+	fmt.Println("  purge --match <glob>   Remove entries whose key or query matches")
+	fmt.Println("  purge --all            Remove all entries")
+	fmt.Println("  export <file>          Export the cache to a .tar.gz archive ('-' for stdout)")
+	fmt.Println("  import <file>          Import a cache archive ('-' for stdin)")
+	fmt.Println("  warm --from <file>     Run the searches in a query file to populate the cache")
+}
+
+func cacheList(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("cache list", flag.ExitOnError)
+	flagExpired := fs.Bool("expired", false, "Only list expired entries")
+	flagJSON := fs.Bool("json", false, "Print entries as JSON")
+	flagDataset := datasetFlag(fs)
+	fs.Parse(args)
+
+	settings, err := datasetSettings(settings, *flagDataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	infos, err := cache.New(settings).List()
+	if err != nil {
+		logger.Logger.Error("Failed to list cache", "dir", settings.CacheDir, "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *flagExpired {
+		filtered := infos[:0]
+		for _, info := range infos {
+			if info.Expired() {
+				filtered = append(filtered, info)
+			}
+		}
+		infos = filtered
+	}
+
+	if *flagJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(infos); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(infos) == 0 {
+		fmt.Println("Cache is empty")
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tQUERY\tFILTERS\tRECORDS\tAGE\tEXPIRES\tSIZE")
+	for _, info := range infos {
+		expires := "in " + formatDuration(time.Until(info.Expiration))
+		if info.Expired() {
+			expires = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			info.Key,
+			info.Query,
+			formatFilters(info.Filters),
+			info.Records,
+			formatDuration(info.Age()),
+			expires,
+			formatSize(info.Size),
+		)
+	}
+	w.Flush()
+
+	return 0
+}
+
+func cacheShow(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("cache show", flag.ExitOnError)
+	flagDataset := datasetFlag(fs)
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: australian-business-data-api cache show [--dataset <name>] <key>")
+		return 2
+	}
+	settings, err := datasetSettings(settings, *flagDataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	entry, err := cache.New(settings).Entry(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entry); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func cachePurge(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("cache purge", flag.ExitOnError)
+	flagMatch := fs.String("match", "", "Remove entries whose key or query matches this glob pattern")
+	flagAll := fs.Bool("all", false, "Remove all entries")
+	flagDataset := datasetFlag(fs)
+	fs.Parse(args)
+
+	settings, err := datasetSettings(settings, *flagDataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *flagAll {
+		logger.Logger.Info("Purging all cache entries", "dir", settings.CacheDir)
+		if err := cache.New(settings).Clean(); err != nil {
+			logger.Logger.Error("Failed to purge cache", "dir", settings.CacheDir, "error", err)
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("Removed all cache entries")
+		return 0
+	}
+
+	if *flagMatch == "" {
+		fmt.Fprintln(os.Stderr, "cache purge requires --match <pattern> or --all")
+		return 2
+	}
+
+	logger.Logger.Info("Purging cache entries", "dir", settings.CacheDir, "match", *flagMatch)
+	removed, err := cache.New(settings).Purge(*flagMatch)
+	if err != nil {
+		logger.Logger.Error("Failed to purge cache", "dir", settings.CacheDir, "match", *flagMatch, "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Removed %d cache entries\n", removed)
+
+	return 0
+}
+
+func cacheExport(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("cache export", flag.ExitOnError)
+	flagDataset := datasetFlag(fs)
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: australian-business-data-api cache export [--dataset <name>] <file>")
+		return 2
+	}
+	settings, err := datasetSettings(settings, *flagDataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	out := os.Stdout
+	if args[0] != "-" {
+		file, err := os.Create(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	count, err := cache.New(settings).Export(out)
+	if err != nil {
+		logger.Logger.Error("Failed to export cache", "dir", settings.CacheDir, "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	logger.Logger.Info("Exported cache entries", "dir", settings.CacheDir, "entries", count)
+	fmt.Fprintf(os.Stderr, "Exported %d cache entries\n", count)
+
+	return 0
+}
+
+func cacheImport(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("cache import", flag.ExitOnError)
+	flagDataset := datasetFlag(fs)
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: australian-business-data-api cache import [--dataset <name>] <file>")
+		return 2
+	}
+	settings, err := datasetSettings(settings, *flagDataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	in := os.Stdin
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		in = file
+	}
+
+	count, err := cache.New(settings).Import(in)
+	if err != nil {
+		logger.Logger.Error("Failed to import cache", "dir", settings.CacheDir, "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	logger.Logger.Info("Imported cache entries", "dir", settings.CacheDir, "entries", count)
+	fmt.Printf("Imported %d cache entries\n", count)
+
+	return 0
+}
+
+// datasetFlag adds the --dataset option that selects which dataset's cache
+// a command works on
+func datasetFlag(fs *flag.FlagSet) *string {
+	return fs.String("dataset", datasetBusinessNames, "Dataset whose cache to use: business-names or companies")
+}
+
+// datasetSettings returns the settings for a dataset's searches and cache
+func datasetSettings(settings config.Settings, dataset string) (config.Settings, error) {
+	switch dataset {
+	case datasetBusinessNames:
+		return settings, nil
+	case datasetCompanies:
+		return settings.Companies(), nil
+	default:
+		return settings, fmt.Errorf("invalid dataset %q, valid values are: %s, %s", dataset, datasetBusinessNames, datasetCompanies)
+	}
+}
+
+func formatFilters(filters map[string]string) string {
+	if len(filters) == 0 {
+		return "-"
+	}
+
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%s", k, filters[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%dB", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	default:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+)
+
+// runConfigCommand handles the "config" subcommand and returns the exit code
+func runConfigCommand(args []string) int {
+	if len(args) == 0 {
+		configUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "show":
+		return configShow(args[1:])
+	case "path":
+		fmt.Println(config.FilePath())
+		return 0
+	case "help", "-h", "--help":
+		configUsage()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown config command: %s\n", args[0])
+		configUsage()
+		return 2
+	}
+}
+
+func configUsage() {
+	fmt.Println("Usage: australian-business-data-api config <command> [options]")
+	fmt.Println("Commands:")
+	fmt.Println("  show [--profile <name>]   Print the effective settings and where each value came from")
+	fmt.Println("  path                      Print the config file location")
+}
+
+func configShow(args []string) int {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	flagProfile := fs.String("profile", "", "Configuration profile to use (default $ABN_PROFILE or the config file's profile)")
+	flagJSON := fs.Bool("json", false, "Print settings as JSON")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*flagProfile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	settings := cfg.Effective()
+
+	if *flagJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(settings); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	file := cfg.LoadedFile()
+	if file == "" {
+		file = config.FilePath() + " (not found)"
+	}
+	profile := cfg.ActiveProfile()
+	if profile == "" {
+		profile = "(none)"
+	}
+	fmt.Println("Config file:", file)
+	fmt.Println("Profile:    ", profile)
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE\tENV")
+	for _, setting := range settings {
+		source := setting.Source
+		if setting.Detail != "" {
+			source += " " + setting.Detail
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", setting.Name, setting.Value, source, setting.Env)
+	}
+	w.Flush()
+
+	return 0
+}
@@ -4,53 +4,158 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"slices"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/mohnish226/australian-business-data-api/pkg/config"
 	"github.com/mohnish226/australian-business-data-api/pkg/logger"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/api"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/cache"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/charts"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/normalize"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/output"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/similarity"
 )
 
 func main() {
-	// Initialize logger
-	if err := logger.Init("logs"); err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
+	if len(os.Args) > 1 && (os.Args[1] == "cache" || os.Args[1] == "config" || os.Args[1] == "audit") {
+		code := runSubcommand(os.Args[1], os.Args[2:])
+		logger.Close()
+		os.Exit(code)
 	}
-	defer logger.Close()
 
-	logger.Logger.Printf("Starting Australian Business Data API")
+	flagProfile := flag.String("profile", "", "Configuration profile to use (default $ABN_PROFILE or the config file's profile)")
+	defaults := config.Default()
+	flag.String("host", defaults.Host, "CKAN portal URL")
+	flag.String("resource-id", defaults.ResourceID, "Datastore resource ID")
+	flag.Int("request-limit", defaults.RequestLimit, "Number of records requested per search")
+	flag.String("cache-dir", defaults.CacheDir, "Directory where cache files are stored")
 
 	flagCleanCache := flag.Bool("clean", false, "Clean the Expired cache")
 	flagNoCache := flag.Bool("nocache", false, "Do not use cache")
-	flagOutput := flag.String("output", "", "Output file")
+	flagOutput := flag.String("output", "", "Output file ('-' for stdout)")
+	flag.StringVar(flagOutput, "o", "", "Shorthand for --output")
 	flagNoOutput := flag.Bool("no-output", false, "Do not output of the results")
-	flagCacheExpiration := flag.Int("cache-expiration", 10, "Cache expiration time in minutes")
+	flagFormat := flag.String("format", "", "Output format: "+strings.Join(output.Formats(), ", ")+" (default from the --output extension, otherwise table)")
+	flag.String("columns", strings.Join(defaults.Headers, ","), "Comma-separated output columns, by field name or label, or 'all'")
+	flagListColumns := flag.Bool("list-columns", false, "List the columns that can be selected with --columns")
+	flag.Int("cache-expiration", 10, "Cache expiration time in minutes")
+	flag.String("cache-compression", defaults.CacheCompression, "Compression for new cache entries (gzip, none)")
+	flag.Int("stale-grace", 1440, "Minutes an expired cache entry may still be served as stale data (0 disables)")
+	flag.Bool("stale-while-revalidate", true, "Serve stale cache entries immediately and refresh them in the background")
 
 	flagSearchTerm := flag.String("search", "", "Search term")
 	flagSearchDate := flag.String("date", "", "Search date")
 	flagSearchState := flag.String("state", "", "Search state")
 	flagSearchRegistrationStatus := flag.String("status", "", "Search registration status")
+	flagRegisteredFrom := flag.String("registered-from", "", "Only include records registered on or after this date (YYYY-MM-DD or DD/MM/YYYY)")
+	flagRegisteredTo := flag.String("registered-to", "", "Only include records registered on or before this date (YYYY-MM-DD or DD/MM/YYYY)")
 
 	flagSearchLike := flag.String("searchlike", "", "Search term for SQL LIKE query")
+	flagDataset := flag.String("dataset", datasetBusinessNames, "Dataset to search: business-names or companies (the ASIC company register)")
+	flagLinkBusinessNames := flag.Bool("link-business-names", false, "Add the business names registered to each company's ABN (companies dataset only)")
 
 	flagGetAverageAge := flag.Bool("average-age", false, "Get average age of businesses")
 	flagGetRegistrationStatusChart := flag.Bool("registration-chart", false, "Get registration status")
 	flagGetRegistrationDistributionChart := flag.Bool("registration-distribution-chart", false, "Get registration distribution chart")
 	flagGetRegistrationStateChart := flag.Bool("registration-state-chart", false, "Get registration state chart")
+	flag.String("table-style", defaults.TableStyle, "Table style: "+strings.Join(output.TableStyles(), ", "))
+	flag.String("table-overflow", defaults.TableOverflow, "How to fit cells wider than the terminal: truncate, wrap or none")
+	flagTableWidth := flag.Int("table-width", 0, "Widest a table may be (default the terminal width when printing to a terminal)")
+	flagSQLDialect := flag.String("sql-dialect", output.DialectPostgres, "SQL dialect for --format sql: postgres or sqlite")
+	flagSQLTable := flag.String("sql-table", output.DefaultSQLTable, "Table created by --format sql")
+	flagXLSXSummary := flag.Bool("xlsx-summary", false, "Add a sheet of the charts' summary statistics to XLSX output")
+
+	flagVerbose := flag.Bool("verbose", false, "Write debug logs to the log file and stderr")
+	flagQuiet := flag.Bool("quiet", false, "Do not write log messages to stderr")
+	flag.String("log-format", defaults.LogFormat, "Log format: text or json")
+	flag.String("log-bodies", defaults.LogBodies, "Log request and response bodies at debug level: off, truncate or full")
+	flag.String("log-dir", defaults.LogDir, "Directory log files are written to")
+	flag.Bool("log-file", true, "Write a log file (use --log-file=false to log to stderr only)")
 
 	flag.Parse()
 
-	// Set cache expiration from flag
-	config.CacheExpiration = time.Duration(*flagCacheExpiration) * time.Minute
+	// Layer the config file and environment under any flags that were set
+	cfg, err := config.Load(*flagProfile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := applyFlagSettings(cfg, flag.CommandLine); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	settings := cfg.Settings
+
+	if *flagVerbose && *flagQuiet {
+		fmt.Println("--verbose and --quiet cannot be used together")
+		os.Exit(2)
+	}
+	dialect, err := output.ParseDialect(*flagSQLDialect)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	// The SQL and table formats have options of their own for this run
+	formatName := output.ResolveFormat(*flagFormat, *flagOutput)
+	var writer output.Writer
+	switch formatName {
+	case output.FormatSQL:
+		writer = output.SQLWriter{Dialect: dialect, Table: *flagSQLTable}
+	case output.FormatTable:
+		tableWidth := *flagTableWidth
+		if tableWidth == 0 && (*flagOutput == "" || *flagOutput == output.Stdout) {
+			tableWidth = output.TerminalWidth()
+		}
+		writer = output.TableWriter{Style: settings.TableStyle, Width: tableWidth, Overflow: settings.TableOverflow}
+	default:
+		if writer, err = output.Lookup(formatName); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	// Initialize logger
+	if err := initLogger(settings, *flagVerbose, *flagQuiet); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Close()
+
+	logger.Logger.Info("Starting Australian Business Data API")
+
+	switch *flagDataset {
+	case datasetBusinessNames:
+		if *flagLinkBusinessNames {
+			fmt.Println("--link-business-names can only be used with --dataset companies")
+			os.Exit(2)
+		}
+	case datasetCompanies:
+		if *flagSearchLike != "" {
+			fmt.Println("--searchlike is not supported for the companies dataset")
+			os.Exit(2)
+		}
+		companySettings := settings.Companies()
+		if cfg.Source("columns") != config.SourceDefault {
+			companySettings.Headers = settings.Headers
+		}
+		settings = companySettings
+	default:
+		fmt.Printf("Invalid dataset %q. Valid values are: %s, %s\n", *flagDataset, datasetBusinessNames, datasetCompanies)
+		os.Exit(2)
+	}
+	logger.Logger.Info("Using configuration", "host", settings.Host, "dataset", *flagDataset, "resource", settings.ResourceID, "profile", cfg.ActiveProfile(), "config_file", cfg.LoadedFile())
 
 	results := []map[string]interface{}{}
-	var err error
+	var result *models.SearchResult
+
+	apiService := api.NewService(settings)
+	defer apiService.Wait()
 
 	flag.Usage = func() {
 		fmt.Println("Usage: australian-business-data-api [options]")
@@ -58,18 +163,27 @@ func main() {
 		flag.PrintDefaults()
 	}
 
+	if *flagListColumns {
+		printColumns(datasetSchema(apiService))
+		os.Exit(0)
+	}
+
 	if *flagCleanCache {
-		logger.Logger.Printf("Cleaning expired cache")
-		if err := cache.RemoveExpiredCache(); err != nil {
-			logger.Logger.Printf("Failed to clean cache: %v", err)
-			os.Exit(1)
+		// Clean every dataset's cache, as company entries live below the
+		// business names cache
+		for _, dataset := range []config.Settings{cfg.Settings, cfg.Settings.Companies()} {
+			logger.Logger.Info("Cleaning expired cache", "dir", dataset.CacheDir)
+			if err := cache.New(dataset).RemoveExpired(); err != nil {
+				logger.Logger.Error("Failed to clean cache", "dir", dataset.CacheDir, "error", err)
+				os.Exit(1)
+			}
+			logger.Logger.Info("Cache cleaned successfully", "dir", dataset.CacheDir)
 		}
-		logger.Logger.Printf("Cache cleaned successfully")
 		os.Exit(0)
 	}
 
 	if *flagNoCache {
-		logger.Logger.Printf("Cache disabled")
+		logger.Logger.Info("Cache disabled")
 		// TODO: Implement cache disabling
 	}
 
@@ -81,80 +195,99 @@ func main() {
 			query = *flagSearchDate
 		}
 
-		filter := map[string]string{}
-
-		if flagSearchState != nil && *flagSearchState != "" {
-			*flagSearchState = strings.ToUpper(*flagSearchState)
-			valid := false
-			for _, validState := range config.ValidStates {
-				if *flagSearchState == validState {
-					valid = true
-					break
-				}
-			}
-			if !valid {
-				fmt.Println("Invalid state. Valid values are:", strings.Join(config.ValidStates, ", "))
-				return
-			}
-			filter["BN_STATE_OF_REG"] = *flagSearchState
+		filterFunc := searchFilters
+		if *flagDataset == datasetCompanies {
+			filterFunc = companyFilters
 		}
-
-		if flagSearchRegistrationStatus != nil && *flagSearchRegistrationStatus != "" {
-			*flagSearchRegistrationStatus = config.StatusAutoCorrect[strings.ToLower(*flagSearchRegistrationStatus)]
-			if *flagSearchRegistrationStatus != "Registered" && *flagSearchRegistrationStatus != "Deregistered" {
-				fmt.Println("Invalid registration status. Valid values are: Registered, Deregistered")
-				return
-			}
-			filter["BN_STATUS"] = *flagSearchRegistrationStatus
+		filter, err := filterFunc(*flagSearchState, *flagSearchRegistrationStatus)
+		if err == nil {
+			err = addRegistrationRange(filter, *flagDataset, *flagRegisteredFrom, *flagRegisteredTo)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
 		}
 
-		apiService := api.NewService()
-		results, err = apiService.BasicSearch(query, filter)
+		result, err = apiService.BasicSearch(query, filter)
 		if err != nil {
-			logger.Logger.Printf("Search failed: %v", err)
+			logger.Logger.Error("Search failed", "query", query, "filters", filter, "error", err)
 			os.Exit(1)
 		}
-		logger.Logger.Printf("Found %d results", len(results))
+		results = result.Records
+		logger.Logger.Info("Search finished", "query", query, "filters", filter, "records", len(results), "from_cache", result.FromCache, "stale", result.Stale, "derived", result.Derived)
 	}
 
 	if *flagSearchLike != "" {
-		logger.Logger.Printf("Performing SQL search with term: %s", *flagSearchLike)
-		apiService := api.NewService()
-		results, err = apiService.SQLSearch(*flagSearchLike)
+		logger.Logger.Info("Performing SQL search", "query", *flagSearchLike)
+		result, err = apiService.SQLSearch(*flagSearchLike)
 		if err != nil {
-			logger.Logger.Printf("SQL search failed: %v", err)
+			logger.Logger.Error("SQL search failed", "query", *flagSearchLike, "error", err)
 			os.Exit(1)
 		}
-		logger.Logger.Printf("Found %d results", len(results))
+		results = result.Records
+		logger.Logger.Info("SQL search finished", "query", *flagSearchLike, "records", len(results), "from_cache", result.FromCache, "stale", result.Stale)
 
 		results = similarity.SortName(results, *flagSearchLike)
-		config.Headers = append(config.Headers, "Match_Percent")
+	}
+
+	if result != nil && result.Stale {
+		if result.RefreshErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: request to %s failed (%v); showing stale cached data from %s ago\n", settings.Host, result.RefreshErr, result.Age().Round(time.Second))
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: showing stale cached data from %s ago; refreshing in the background\n", result.Age().Round(time.Second))
+		}
+	}
+
+	if result != nil && result.Derived {
+		fmt.Fprintf(os.Stderr, "Note: results filtered from a cached broader search from %s ago\n", result.Age().Round(time.Second))
 	}
 
 	if len(results) == 0 {
-		fmt.Println("No records found")
+		fmt.Fprintln(os.Stderr, "No records found")
 		return
 	}
 
-	if flagOutput != nil && *flagOutput != "" {
-		if strings.HasSuffix(*flagOutput, ".csv") {
-			err := output.CSVWriter(results, *flagOutput)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
-		} else {
-			err := output.TerminalTablePrint(results, *flagOutput)
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
+	if *flagLinkBusinessNames {
+		businessNames := api.NewService(cfg.Settings)
+		if err := api.LinkBusinessNames(results, businessNames); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: some business names could not be linked: %v\n", err)
 		}
-	} else {
-		if flagNoOutput != nil && *flagNoOutput {
-			// Do nothing
-		} else {
-			output.TerminalTablePrint(results, "")
+		businessNames.Wait()
+	}
+
+	// Results from the cache are written with the schema already known, so a
+	// cache hit makes no request to the portal
+	schema := apiService.KnownSchema()
+	if result == nil || !result.FromCache {
+		schema = datasetSchema(apiService)
+	}
+	columns, err := output.Columns(results, settings.Headers, schema)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *flagSearchLike != "" {
+		columns = appendColumn(columns, "Match_Percent")
+	}
+	if *flagLinkBusinessNames {
+		columns = appendColumn(columns, api.BusinessNamesField)
+	}
+
+	if !*flagNoOutput || *flagOutput != "" {
+		meta := output.Metadata{ResourceID: settings.ResourceID}
+		if result != nil {
+			meta.Query = result.Query
+			meta.FetchedAt = result.FetchedAt
+			meta.FromCache = result.FromCache
+			meta.Stale = result.Stale
+		}
+		if *flagXLSXSummary {
+			stats := charts.GetBusinessStats(results)
+			meta.Stats = &stats
+		}
+		if err := output.WriteTo(writer, *flagOutput, results, columns, meta); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 	}
 
@@ -179,5 +312,243 @@ func main() {
 		charts.GetRegistrationStateChart(results)
 	}
 
-	logger.Logger.Printf("Application completed successfully")
+	logger.Logger.Info("Application completed successfully")
+}
+
+// searchFilters validates the state and registration status options and
+// returns the datastore filters for them
+func searchFilters(state, status string) (map[string]string, error) {
+	filter := map[string]string{}
+
+	if state != "" {
+		state, err := normalize.State(state)
+		if err != nil {
+			return nil, err
+		}
+		filter["BN_STATE_OF_REG"] = state
+	}
+
+	if status != "" {
+		status, err := normalize.Status(status)
+		if err != nil {
+			return nil, err
+		}
+		filter["BN_STATUS"] = status
+	}
+
+	return filter, nil
+}
+
+// companyFilters validates the state and registration status options and
+// returns the company register filters for them
+func companyFilters(state, status string) (map[string]string, error) {
+	if state != "" {
+		return nil, fmt.Errorf("The companies dataset cannot be filtered by state")
+	}
+
+	filter := map[string]string{}
+	if status != "" {
+		status, err := normalize.CompanyStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		filter["Status"] = status
+	}
+
+	return filter, nil
+}
+
+// addRegistrationRange adds a filter on the registration date of dataset
+// for the --registered-from and --registered-to options. Either may be left
+// empty for an open range
+func addRegistrationRange(filter map[string]string, dataset, from, to string) error {
+	if from == "" && to == "" {
+		return nil
+	}
+
+	field := "BN_REG_DT"
+	if dataset == datasetCompanies {
+		field = "Date of Registration"
+	}
+	value, err := normalize.DateRange(from + models.DateRangeSeparator + to)
+	if err != nil {
+		return err
+	}
+	filter[field] = value
+	return nil
+}
+
+// appendColumn adds a column for field unless columns already has one
+func appendColumn(columns []output.Column, field string) []output.Column {
+	if slices.ContainsFunc(columns, func(c output.Column) bool { return c.Field == field }) {
+		return columns
+	}
+	title := field
+	if name, ok := config.HeadersMap[field]; ok {
+		title = name
+	}
+	return append(columns, output.Column{Field: field, Title: title})
+}
+
+// runSubcommand loads the configuration and runs a subcommand, returning
+// its exit code. Subcommands use the profile from a --profile option
+// anywhere in args, falling back to $ABN_PROFILE
+func runSubcommand(name string, args []string) int {
+	if name == "config" {
+		return runConfigCommand(args)
+	}
+
+	profile, args, err := profileArg(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg, err := config.Load(profile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := initLogger(cfg.Settings, false, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		return 1
+	}
+	logger.Logger.Info("Starting Australian Business Data API", "command", name)
+
+	if name == "audit" {
+		return runAuditCommand(cfg.Settings, args)
+	}
+	return runCacheCommand(cfg.Settings, args)
+}
+
+// profileArg removes a --profile option from a subcommand's arguments and
+// returns its value and the remaining arguments. Arguments after "--" are
+// left alone
+func profileArg(args []string) (string, []string, error) {
+	profile := ""
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "profile" {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("--profile requires a profile name")
+			}
+			i++
+			value = args[i]
+		}
+		profile = value
+	}
+	return profile, rest, nil
+}
+
+// initLogger starts logging to the log file at the configured level and
+// format. Errors are also written to stderr unless quiet is set, and verbose
+// logs everything from debug up to both
+func initLogger(settings config.Settings, verbose, quiet bool) error {
+	level, err := logger.ParseLevel(settings.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	opts := logger.Options{
+		MaxSize:     int64(settings.LogRotateSize) << 20,
+		MaxAge:      settings.LogRotateAge,
+		MaxFiles:    settings.LogRetainFiles,
+		RetainAge:   settings.LogRetainAge,
+		Format:      settings.LogFormat,
+		Level:       level,
+		Stderr:      os.Stderr,
+		StderrLevel: slog.LevelError,
+	}
+	if verbose {
+		opts.Level = slog.LevelDebug
+		opts.StderrLevel = slog.LevelDebug
+	}
+	if settings.LogToFile {
+		opts.Dir = settings.LogDir
+	}
+	if quiet {
+		opts.Stderr = nil
+	}
+
+	return logger.Init(opts)
+}
+
+// Datasets that can be chosen with --dataset
+const (
+	datasetBusinessNames = "business-names"
+	datasetCompanies     = "companies"
+)
+
+// settingFlags maps command-line flags to the config settings they override
+var settingFlags = map[string]string{
+	"host":                   "host",
+	"resource-id":            "resource_id",
+	"request-limit":          "request_limit",
+	"cache-dir":              "cache_dir",
+	"cache-expiration":       "cache_expiration",
+	"cache-compression":      "cache_compression",
+	"stale-grace":            "cache_stale_grace",
+	"stale-while-revalidate": "stale_while_revalidate",
+	"log-format":             "log_format",
+	"log-bodies":             "log_bodies",
+	"table-style":            "table_style",
+	"table-overflow":         "table_overflow",
+	"log-dir":                "log_dir",
+	"log-file":               "log_to_file",
+	"columns":                "columns",
+}
+
+// applyFlagSettings overrides config settings with the flags that were set
+// on the command line, leaving the others to the config file and environment
+func applyFlagSettings(cfg *config.Config, fs *flag.FlagSet) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		name, ok := settingFlags[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		err = cfg.Set(name, f.Value.String(), config.SourceFlag, "--"+f.Name)
+	})
+	return err
+}
+
+// datasetSchema returns the schema of the dataset, warning when it cannot be
+// retrieved or no longer has fields the output relies on
+func datasetSchema(apiService *api.Service) *models.Schema {
+	schema, err := apiService.Schema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not retrieve the dataset schema (%v); using the built-in columns\n", err)
+		return apiService.DefaultSchema()
+	}
+
+	if missing := schema.Missing(apiService.ExpectedColumns()); len(missing) > 0 {
+		logger.Logger.Warn("Expected fields missing from the dataset schema", "resource", schema.ResourceID, "fields", missing)
+		fmt.Fprintf(os.Stderr, "Warning: the dataset no longer has the fields: %s\n", strings.Join(missing, ", "))
+	}
+	return schema
+}
+
+// printColumns lists the columns that can be selected with --columns
+func printColumns(schema *models.Schema) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLUMN\tNAME\tTYPE")
+	for _, field := range schema.Fields {
+		if strings.HasPrefix(field.ID, "_") {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", field.ID, field.Label, field.Type)
+	}
+	w.Flush()
+	fmt.Println("\nUse --columns all to include every field returned by the search")
 }
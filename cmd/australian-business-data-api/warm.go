@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// warmQuery is a single search listed in a warm-up query file
+type warmQuery struct {
+	Query  string `yaml:"query"`
+	State  string `yaml:"state"`
+	Status string `yaml:"status"`
+	// SQL runs the query through the datastore_search_sql endpoint
+	SQL bool `yaml:"sql"`
+}
+
+// warmResult records the outcome of warming a single query
+type warmResult struct {
+	query     warmQuery
+	filters   map[string]string
+	records   int
+	fromCache bool
+	duration  time.Duration
+	err       error
+}
+
+func cacheWarm(settings config.Settings, args []string) int {
+	fs := flag.NewFlagSet("cache warm", flag.ExitOnError)
+	flagFrom := fs.String("from", "", "Query file: one search term per line, or YAML with query, state, status and sql keys")
+	flagConcurrency := fs.Int("concurrency", 4, "Number of searches to run at the same time")
+	flagCacheExpiration := fs.Int("cache-expiration", 0, "Cache expiration time in minutes for warmed entries (default the cache_expiration setting)")
+	flagDataset := datasetFlag(fs)
+	flagRefresh := fs.Bool("refresh", true, "Fetch every query, even when its cache entry has not expired (use --refresh=false to keep fresh entries)")
+	fs.Parse(args)
+
+	if *flagFrom == "" {
+		fmt.Fprintln(os.Stderr, "cache warm requires --from <file>")
+		return 2
+	}
+	if *flagConcurrency < 1 {
+		fmt.Fprintln(os.Stderr, "--concurrency must be at least 1")
+		return 2
+	}
+	// The cache_expiration setting applies unless the flag was given
+	expirationSet := false
+	fs.Visit(func(f *flag.Flag) {
+		expirationSet = expirationSet || f.Name == "cache-expiration"
+	})
+	if expirationSet && *flagCacheExpiration < 1 {
+		fmt.Fprintln(os.Stderr, "--cache-expiration must be at least 1")
+		return 2
+	}
+	settings, err := datasetSettings(settings, *flagDataset)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	filterFunc := searchFilters
+	if *flagDataset == datasetCompanies {
+		filterFunc = companyFilters
+	}
+
+	queries, err := readWarmQueries(*flagFrom)
+	if err != nil {
+		logger.Logger.Error("Failed to read warm-up queries", "file", *flagFrom, "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(queries) == 0 {
+		fmt.Println("No queries to warm")
+		return 0
+	}
+
+	if expirationSet {
+		settings.CacheExpiration = time.Duration(*flagCacheExpiration) * time.Minute
+	}
+	// Warmed entries should be fresh, so wait on the API instead of serving stale data
+	settings.StaleWhileRevalidate = false
+	settings.CacheRefresh = *flagRefresh
+
+	logger.Logger.Info("Warming cache", "queries", len(queries), "file", *flagFrom, "dataset", *flagDataset, "concurrency", *flagConcurrency)
+
+	apiService := api.NewService(settings)
+	results := make([]warmResult, len(queries))
+	sem := make(chan struct{}, *flagConcurrency)
+	var wg sync.WaitGroup
+
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query warmQuery) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			start := time.Now()
+			results[i] = warmOne(apiService, query, filterFunc)
+			results[i].duration = time.Since(start)
+		}(i, query)
+	}
+	wg.Wait()
+	apiService.Wait()
+
+	return printWarmSummary(results)
+}
+
+// warmOne runs a single warm-up query, building its filters with
+// filterFunc. A stale entry served because its refresh failed counts as a
+// failure
+func warmOne(apiService *api.Service, query warmQuery, filterFunc func(state, status string) (map[string]string, error)) warmResult {
+	result := warmResult{query: query}
+
+	var searchResult *models.SearchResult
+	var err error
+	if query.SQL {
+		searchResult, err = apiService.SQLSearch(query.Query)
+	} else {
+		result.filters, err = filterFunc(query.State, query.Status)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		searchResult, err = apiService.BasicSearch(query.Query, result.filters)
+	}
+	if err != nil {
+		result.err = err
+		return result
+	}
+	if searchResult.RefreshErr != nil {
+		result.err = fmt.Errorf("refresh failed, stale entry kept: %v", searchResult.RefreshErr)
+		return result
+	}
+
+	result.records = len(searchResult.Records)
+	result.fromCache = searchResult.FromCache
+	return result
+}
+
+// printWarmSummary prints the outcome of every query and the totals, and
+// returns the exit code
+func printWarmSummary(results []warmResult) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUERY\tFILTERS\tRECORDS\tSOURCE\tDURATION\tRESULT")
+
+	succeeded, failed, records := 0, 0, 0
+	for _, result := range results {
+		outcome, source := "ok", "network"
+		if result.fromCache {
+			source = "cache"
+		}
+		if result.err != nil {
+			outcome, source = "failed: "+result.err.Error(), "-"
+			failed++
+			logger.Logger.Warn("Warm-up failed", "query", result.query.Query, "filters", result.filters, "duration", result.duration, "error", result.err)
+		} else {
+			succeeded++
+			records += result.records
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n",
+			result.query.Query,
+			formatFilters(result.filters),
+			result.records,
+			source,
+			result.duration.Round(time.Millisecond),
+			outcome,
+		)
+	}
+	w.Flush()
+
+	fmt.Printf("\nWarmed %d of %d queries, %d failed, %d records cached\n", succeeded, len(results), failed, records)
+	logger.Logger.Info("Cache warm-up finished", "succeeded", succeeded, "failed", failed, "records", records)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readWarmQueries loads warm-up queries from a YAML file (.yaml or .yml) or
+// a plain text file with one search term per line. Blank lines and lines
+// starting with # are ignored in text files
+func readWarmQueries(filename string) ([]warmQuery, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".yaml" || ext == ".yml" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		var queries []warmQuery
+		if err := yaml.Unmarshal(data, &queries); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+		}
+		for i, query := range queries {
+			if strings.TrimSpace(query.Query) == "" {
+				return nil, fmt.Errorf("%s: entry %d has no query", filename, i+1)
+			}
+		}
+		return queries, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []warmQuery
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		queries = append(queries, warmQuery{Query: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return queries, nil
+}
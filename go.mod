@@ -1,3 +1,14 @@
 module github.com/mohnish226/australian-business-data-api
 
 go 1.21
+
+require (
+	github.com/mattn/go-runewidth v0.0.15
+	golang.org/x/sync v0.6.0
+	golang.org/x/sys v0.15.0
+	golang.org/x/term v0.15.0
+	golang.org/x/text v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/rivo/uniseg v0.2.0 // indirect
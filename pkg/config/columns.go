@@ -0,0 +1,78 @@
+package config
+
+// ColumnsAll selects every field of the dataset
+const ColumnsAll = "all"
+
+// CompaniesCacheDir is the directory below the cache directory that holds
+// company searches
+const CompaniesCacheDir = "companies"
+
+// Columns are the fields expected in the business names dataset, in dataset
+// order. They are used when the schema cannot be retrieved, and a warning is
+// shown when the schema no longer has one of them
+var Columns = []string{
+	"REGISTER_NAME",
+	"BN_NAME",
+	"BN_STATUS",
+	"BN_REG_DT",
+	"BN_CANCEL_DT",
+	"BN_RENEW_DT",
+	"BN_STATE_NUM",
+	"BN_STATE_OF_REG",
+	"BN_ABN",
+}
+
+// CompanyColumns are the fields expected in the ASIC company register, in
+// dataset order
+var CompanyColumns = []string{
+	"Company Name",
+	"ACN",
+	"Type",
+	"Class",
+	"Sub Class",
+	"Status",
+	"Date of Registration",
+	"Date of Deregistration",
+	"Previous State of Registration",
+	"State Registration number",
+	"Modified since last report",
+	"Current Name Indicator",
+	"ABN",
+	"Current Name",
+	"Current Name Start Date",
+}
+
+// CompanyHeaders are the default output columns for company searches
+var CompanyHeaders = []string{
+	"Company Name",
+	"ACN",
+	"Type",
+	"Class",
+	"Status",
+	"Date of Registration",
+	"Date of Deregistration",
+	"ABN",
+}
+
+// DateColumns are the fields that hold dates, written as DD/MM/YYYY in both
+// datasets
+var DateColumns = []string{
+	"BN_REG_DT",
+	"BN_CANCEL_DT",
+	"BN_RENEW_DT",
+	"Date of Registration",
+	"Date of Deregistration",
+	"Current Name Start Date",
+}
+
+// IdentifierColumns are the fields that hold numbers which identify rather
+// than count, such as ABNs and ACNs. They are written as text where a
+// format would otherwise drop their leading zeros
+var IdentifierColumns = []string{
+	"BN_ABN",
+	"BN_STATE_NUM",
+	"ABN",
+	"ACN",
+	"State Registration number",
+	"POSTCODE",
+}
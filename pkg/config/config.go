@@ -3,28 +3,80 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"time"
 )
 
 const (
-	Host                   = "https://data.gov.au"
-	RestPath               = "/data/api/action/datastore_search"
-	SQLPath                = "/data/api/action/datastore_search_sql"
-	ResourceID             = "55ad4b1c-5eeb-44ea-8b29-d410da431be3"
-	RequestLimit           = 50
-	APIToken               = ""
 	DefaultCacheExpiration = time.Minute * 10 // 10 minutes
-)
 
-var (
-	// APIKey is the key for the Australian Business Data API
-	APIKey = os.Getenv("ABN_API_KEY")
+	// DefaultHost is the CKAN portal the datastore requests are sent to
+	DefaultHost = "https://data.gov.au"
 
-	// CacheDir is the directory where cache files are stored
-	CacheDir = filepath.Join(os.TempDir(), "abn-cache")
+	// DefaultRestPath is the path of the datastore_search endpoint
+	DefaultRestPath = "/data/api/action/datastore_search"
 
-	// CacheExpiration is the duration for which cached data remains valid
-	CacheExpiration = 24 * time.Hour
+	// DefaultSQLPath is the path of the datastore_search_sql endpoint
+	DefaultSQLPath = "/data/api/action/datastore_search_sql"
+
+	// DefaultInfoPath is the path of the datastore_info endpoint
+	DefaultInfoPath = "/data/api/action/datastore_info"
+
+	// DefaultResourceID is the datastore resource holding the business names
+	DefaultResourceID = "55ad4b1c-5eeb-44ea-8b29-d410da431be3"
+
+	// DefaultCompanyResourceID is the datastore resource holding the ASIC
+	// company register
+	DefaultCompanyResourceID = "5c3914e6-413e-4a2c-b890-bf8efe3eabf2"
+
+	// DefaultRequestLimit is the number of records requested per search
+	DefaultRequestLimit = 50
+
+	// DefaultCacheStaleGrace is how long after expiring a cache entry may
+	// still be served as stale data
+	DefaultCacheStaleGrace = 24 * time.Hour
+
+	// DefaultCacheCompression is the compression applied to new cache entries
+	DefaultCacheCompression = "gzip"
+
+	// DefaultLogFormat is the format of log records, "text" or "json"
+	DefaultLogFormat = "text"
+
+	// DefaultLogLevel is the lowest level written to the log file
+	DefaultLogLevel = "info"
+
+	// DefaultTableStyle is how tables printed to the terminal are drawn
+	DefaultTableStyle = "classic"
+
+	// DefaultTableOverflow is how cells too wide for the terminal are fitted
+	DefaultTableOverflow = "truncate"
+
+	// DefaultLogBodies is how request and response bodies are logged: "off",
+	// "truncate" or "full"
+	DefaultLogBodies = "truncate"
+
+	// DefaultLogBodyLimit is the number of bytes of each body logged when
+	// bodies are truncated
+	DefaultLogBodyLimit = 4096
+
+	// AuditFileName is the name of the audit log in the default log
+	// directory
+	AuditFileName = "audit.log"
+
+	// DefaultLogRotateSize is the size in megabytes after which a new log
+	// file is started
+	DefaultLogRotateSize = 10
+
+	// DefaultLogRotateAge is how long a log file is written to before a new
+	// one is started
+	DefaultLogRotateAge = 24 * time.Hour
+
+	// DefaultLogRetainFiles is the number of log files kept
+	DefaultLogRetainFiles = 10
+
+	// DefaultLogRetainAge is how long log files are kept after their last
+	// write
+	DefaultLogRetainAge = 30 * 24 * time.Hour
 
 	// APIBaseURL is the base URL for the Australian Business Data API
 	APIBaseURL = "https://abr.business.gov.au/json/AbnDetails.aspx"
@@ -33,6 +85,184 @@ var (
 	MaxResults = 100
 )
 
+var (
+	// APIKey is the key for the Australian Business Data API
+	APIKey = os.Getenv("ABN_API_KEY")
+)
+
+// Settings holds the values that control a search, its caching and its
+// output. Values are passed explicitly to the api, cache and output
+// packages, so services with different settings can run side by side
+type Settings struct {
+	// Host is the CKAN portal the datastore requests are sent to
+	Host string
+	// RestPath is the path of the datastore_search endpoint
+	RestPath string
+	// SQLPath is the path of the datastore_search_sql endpoint
+	SQLPath string
+	// InfoPath is the path of the datastore_info endpoint
+	InfoPath string
+	// ResourceID is the datastore resource that is searched
+	ResourceID string
+	// CompanyResourceID is the datastore resource holding the ASIC company
+	// register
+	CompanyResourceID string
+	// RequestLimit is the number of records requested per search
+	RequestLimit int
+	// APIToken is sent in the Authorization header to portals that require
+	// authentication
+	APIToken string
+	// APITokenFile is a credentials file holding the API token, read when
+	// APIToken is not set
+	APITokenFile string
+
+	// CacheDir is the directory where cache files are stored
+	CacheDir string
+	// CacheExpiration is the duration for which cached data remains valid
+	CacheExpiration time.Duration
+	// CacheStaleGrace is how long after expiring a cache entry may still be
+	// served as stale data
+	CacheStaleGrace time.Duration
+	// CacheCompression is the compression applied to new cache entries,
+	// either "gzip" or "none"
+	CacheCompression string
+	// StaleWhileRevalidate returns stale cache entries immediately and
+	// refreshes them in the background instead of waiting on the API
+	StaleWhileRevalidate bool
+	// CacheRefresh fetches every search from the API without looking in the
+	// cache first, and stores the result. It refreshes entries that have not
+	// expired yet
+	CacheRefresh bool
+
+	// LogFormat is the format of log records, "text" or "json"
+	LogFormat string
+	// LogLevel is the lowest level written to the log file: debug, info,
+	// warn or error
+	LogLevel string
+	// LogBodies is how request and response bodies are logged at debug
+	// level: "off", "truncate" or "full"
+	LogBodies string
+	// LogBodyLimit is the number of bytes of each body logged when LogBodies
+	// is "truncate"
+	LogBodyLimit int
+	// LogRedactFields are JSON fields whose values are hidden in logged
+	// bodies, in addition to tokens, keys and passwords
+	LogRedactFields []string
+	// LogDir is the directory log files are written to
+	LogDir string
+	// AuditLog is the file every search is recorded in. Searches are not
+	// audited when it is empty
+	AuditLog string
+	// LogToFile enables the log file. When false, logs only go to stderr
+	LogToFile bool
+	// LogRotateSize is the size in megabytes after which a new log file is
+	// started
+	LogRotateSize int
+	// LogRotateAge is how long a log file is written to before a new one is
+	// started
+	LogRotateAge time.Duration
+	// LogRetainFiles is the number of log files kept in LogDir
+	LogRetainFiles int
+	// LogRetainAge is how long log files are kept after their last write
+	LogRetainAge time.Duration
+
+	// TableStyle is how tables are drawn: classic, ascii, light, rounded,
+	// heavy or double
+	TableStyle string
+	// TableOverflow is how cells are fitted when a table is wider than the
+	// terminal: truncate, wrap or none
+	TableOverflow string
+
+	// Headers are the record fields written to the output, in order, by
+	// field name or label. A single ColumnsAll entry selects every field
+	Headers []string
+	// Columns are the fields expected in the resource. They are used when
+	// its schema cannot be retrieved
+	Columns []string
+}
+
+// Default returns the built-in settings
+func Default() Settings {
+	return Settings{
+		Host:                 DefaultHost,
+		RestPath:             DefaultRestPath,
+		SQLPath:              DefaultSQLPath,
+		InfoPath:             DefaultInfoPath,
+		ResourceID:           DefaultResourceID,
+		CompanyResourceID:    DefaultCompanyResourceID,
+		RequestLimit:         DefaultRequestLimit,
+		CacheDir:             filepath.Join(os.TempDir(), "abn-cache"),
+		CacheExpiration:      DefaultCacheExpiration,
+		CacheStaleGrace:      DefaultCacheStaleGrace,
+		CacheCompression:     DefaultCacheCompression,
+		StaleWhileRevalidate: true,
+		LogFormat:            DefaultLogFormat,
+		LogLevel:             DefaultLogLevel,
+		TableStyle:           DefaultTableStyle,
+		TableOverflow:        DefaultTableOverflow,
+		LogBodies:            DefaultLogBodies,
+		LogBodyLimit:         DefaultLogBodyLimit,
+		LogDir:               DefaultLogDir(),
+		AuditLog:             filepath.Join(DefaultLogDir(), AuditFileName),
+		LogToFile:            true,
+		LogRotateSize:        DefaultLogRotateSize,
+		LogRotateAge:         DefaultLogRotateAge,
+		LogRetainFiles:       DefaultLogRetainFiles,
+		LogRetainAge:         DefaultLogRetainAge,
+		Headers:              append([]string(nil), Headers...),
+		Columns:              append([]string(nil), Columns...),
+	}
+}
+
+// DefaultLogDir returns the directory log files are written to by default:
+// ~/Library/Logs on macOS, the user cache directory on Windows and
+// $XDG_STATE_HOME, or ~/.local/state, elsewhere
+func DefaultLogDir() string {
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "darwin":
+		if home != "" {
+			return filepath.Join(home, "Library", "Logs", AppName)
+		}
+	case "windows":
+		if dir, err := os.UserCacheDir(); err == nil {
+			return filepath.Join(dir, AppName, "logs")
+		}
+	default:
+		if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+			return filepath.Join(dir, AppName, "logs")
+		}
+		if home != "" {
+			return filepath.Join(home, ".local", "state", AppName, "logs")
+		}
+	}
+
+	return filepath.Join(os.TempDir(), AppName, "logs")
+}
+
+// Clone returns a copy of s that shares no slices with it
+func (s Settings) Clone() Settings {
+	s.Headers = append([]string(nil), s.Headers...)
+	s.Columns = append([]string(nil), s.Columns...)
+	s.LogRedactFields = append([]string(nil), s.LogRedactFields...)
+	return s
+}
+
+// Companies returns settings for searching the ASIC company register: the
+// company resource with its expected fields and default columns, and a
+// cache directory of its own below CacheDir
+func (s Settings) Companies() Settings {
+	s = s.Clone()
+	s.ResourceID = s.CompanyResourceID
+	s.CacheDir = filepath.Join(s.CacheDir, CompaniesCacheDir)
+	s.Headers = append([]string(nil), CompanyHeaders...)
+	s.Columns = append([]string(nil), CompanyColumns...)
+	return s
+}
+
+// Headers are the default output columns. Use Settings.Headers for the
+// columns of a particular search
 var Headers = []string{
 	"BN_NAME",
 	"BN_STATE_OF_REG",
@@ -41,12 +271,19 @@ var Headers = []string{
 	"BN_CANCEL_DT",
 }
 
+// HeadersMap gives the friendly name of each known output column. Labels
+// from the dataset schema take precedence
 var HeadersMap = map[string]string{
+	"REGISTER_NAME":   "Register Name",
 	"BN_NAME":         "Business Name",
-	"BN_STATE_OF_REG": "State of Registration",
 	"BN_STATUS":       "Status",
 	"BN_REG_DT":       "Registration Date",
 	"BN_CANCEL_DT":    "Cancellation Date",
+	"BN_RENEW_DT":     "Renewal Date",
+	"BN_STATE_NUM":    "State Registration Number",
+	"BN_STATE_OF_REG": "State of Registration",
+	"BN_ABN":          "ABN",
+	"Match_Percent":   "Match Percent",
 }
 
 var ValidStates = []string{
@@ -60,6 +297,22 @@ var ValidStates = []string{
 	"WA",
 }
 
+// CompanyStatusCodes gives the company register's status code for each
+// canonical registration status
+var CompanyStatusCodes = map[string]string{
+	"Registered":   "REGD",
+	"Deregistered": "DRGD",
+}
+
+// ValidCompanyStatuses are the status codes used in the company register
+var ValidCompanyStatuses = []string{
+	"REGD",
+	"DRGD",
+	"EXAD",
+	"NOAC",
+	"SOFF",
+}
+
 var StatusAutoCorrect = map[string]string{
 	"register":     "Registered",
 	"registered":   "Registered",
@@ -0,0 +1,448 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources a setting's effective value can come from, lowest precedence first
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+const (
+	// AppName names the directory the config file lives in
+	AppName = "australian-business-data-api"
+
+	// ConfigFileEnv overrides the config file location
+	ConfigFileEnv = "ABN_CONFIG"
+
+	// ProfileEnv selects a profile when none is given on the command line
+	ProfileEnv = "ABN_PROFILE"
+
+	// CredentialsFileName is the credentials file read from the config
+	// directory when no API token or token file is configured
+	CredentialsFileName = "credentials"
+)
+
+// Setting describes the effective value of one configurable setting
+type Setting struct {
+	// Name is the setting's key in the config file
+	Name string `json:"name"`
+	// Env is the environment variable that overrides the setting
+	Env    string `json:"env"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	// Detail says which file, profile, variable or flag set the value
+	Detail string `json:"detail,omitempty"`
+}
+
+// Config is a loaded set of settings along with where each value came from
+type Config struct {
+	Settings
+
+	sources map[string]Setting
+	file    string
+	profile string
+}
+
+// value reads and writes one field of Settings as a string
+type value interface {
+	String() string
+	Set(string) error
+}
+
+// setting binds a field of Settings to its config key and environment
+// variable
+type setting struct {
+	name  string
+	env   string
+	value func(*Settings) value
+}
+
+var settings = []setting{
+	{"host", "ABN_HOST", func(s *Settings) value { return (*stringValue)(&s.Host) }},
+	{"rest_path", "ABN_REST_PATH", func(s *Settings) value { return (*stringValue)(&s.RestPath) }},
+	{"sql_path", "ABN_SQL_PATH", func(s *Settings) value { return (*stringValue)(&s.SQLPath) }},
+	{"info_path", "ABN_INFO_PATH", func(s *Settings) value { return (*stringValue)(&s.InfoPath) }},
+	{"resource_id", "ABN_RESOURCE_ID", func(s *Settings) value { return (*stringValue)(&s.ResourceID) }},
+	{"api_token", "ABN_API_TOKEN", func(s *Settings) value { return (*secretValue)(&s.APIToken) }},
+	{"api_token_file", "ABN_API_TOKEN_FILE", func(s *Settings) value { return (*stringValue)(&s.APITokenFile) }},
+	{"company_resource_id", "ABN_COMPANY_RESOURCE_ID", func(s *Settings) value { return (*stringValue)(&s.CompanyResourceID) }},
+	{"request_limit", "ABN_REQUEST_LIMIT", func(s *Settings) value { return (*positiveIntValue)(&s.RequestLimit) }},
+	{"cache_dir", "ABN_CACHE_DIR", func(s *Settings) value { return (*stringValue)(&s.CacheDir) }},
+	{"cache_expiration", "ABN_CACHE_EXPIRATION", func(s *Settings) value { return (*durationValue)(&s.CacheExpiration) }},
+	{"cache_stale_grace", "ABN_CACHE_STALE_GRACE", func(s *Settings) value { return (*durationValue)(&s.CacheStaleGrace) }},
+	{"stale_while_revalidate", "ABN_STALE_WHILE_REVALIDATE", func(s *Settings) value { return (*boolValue)(&s.StaleWhileRevalidate) }},
+	{"cache_compression", "ABN_CACHE_COMPRESSION", func(s *Settings) value { return &choiceValue{&s.CacheCompression, []string{"gzip", "none"}} }},
+	{"log_format", "ABN_LOG_FORMAT", func(s *Settings) value { return &choiceValue{&s.LogFormat, []string{"text", "json"}} }},
+	{"log_level", "ABN_LOG_LEVEL", func(s *Settings) value { return &choiceValue{&s.LogLevel, []string{"debug", "info", "warn", "error"}} }},
+	{"log_bodies", "ABN_LOG_BODIES", func(s *Settings) value { return &choiceValue{&s.LogBodies, []string{"off", "truncate", "full"}} }},
+	{"log_body_limit", "ABN_LOG_BODY_LIMIT", func(s *Settings) value { return (*positiveIntValue)(&s.LogBodyLimit) }},
+	{"log_redact_fields", "ABN_LOG_REDACT_FIELDS", func(s *Settings) value { return (*listValue)(&s.LogRedactFields) }},
+	{"log_dir", "ABN_LOG_DIR", func(s *Settings) value { return (*stringValue)(&s.LogDir) }},
+	{"log_to_file", "ABN_LOG_TO_FILE", func(s *Settings) value { return (*boolValue)(&s.LogToFile) }},
+	{"log_rotate_size", "ABN_LOG_ROTATE_SIZE", func(s *Settings) value { return (*positiveIntValue)(&s.LogRotateSize) }},
+	{"log_rotate_age", "ABN_LOG_ROTATE_AGE", func(s *Settings) value { return (*durationValue)(&s.LogRotateAge) }},
+	{"log_retain_files", "ABN_LOG_RETAIN_FILES", func(s *Settings) value { return (*positiveIntValue)(&s.LogRetainFiles) }},
+	{"log_retain_age", "ABN_LOG_RETAIN_AGE", func(s *Settings) value { return (*durationValue)(&s.LogRetainAge) }},
+	{"audit_log", "ABN_AUDIT_LOG", func(s *Settings) value { return (*stringValue)(&s.AuditLog) }},
+	{"table_style", "ABN_TABLE_STYLE", func(s *Settings) value {
+		return &choiceValue{&s.TableStyle, []string{"classic", "ascii", "light", "rounded", "heavy", "double"}}
+	}},
+	{"table_overflow", "ABN_TABLE_OVERFLOW", func(s *Settings) value { return &choiceValue{&s.TableOverflow, []string{"truncate", "wrap", "none"}} }},
+	{"columns", "ABN_COLUMNS", func(s *Settings) value { return (*listValue)(&s.Headers) }},
+}
+
+// fileConfig is the layout of the config file. Top-level keys apply to every
+// profile; keys under profiles.<name> apply when that profile is selected
+type fileConfig struct {
+	Values map[string]interface{} `yaml:",inline"`
+	// Profile is the profile used when none is selected with a flag or
+	// ABN_PROFILE
+	Profile  string                            `yaml:"profile"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// FilePath returns the location of the config file: $ABN_CONFIG if set,
+// otherwise config.yaml in this application's user config directory
+func FilePath() string {
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, AppName, "config.yaml")
+}
+
+// Load starts from the default settings and applies, in order, the config
+// file, the selected profile from the config file and environment variables.
+// profile selects a named profile; when empty, $ABN_PROFILE and then the
+// file's profile key are used. Flags are applied afterwards with Set
+func Load(profile string) (*Config, error) {
+	c := &Config{
+		Settings: Default(),
+		sources:  map[string]Setting{},
+	}
+	for _, s := range settings {
+		c.sources[s.name] = Setting{Name: s.name, Env: s.env, Source: SourceDefault}
+	}
+
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+
+	path := FilePath()
+	var file fileConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		default:
+			if err := yaml.Unmarshal(data, &file); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+			}
+			c.file = path
+		}
+	}
+
+	if err := c.applyValues(file.Values, SourceFile, path); err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		profile = file.Profile
+	}
+	if profile != "" {
+		values, ok := file.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", profile)
+		}
+		if err := c.applyValues(values, SourceFile, fmt.Sprintf("%s (profile %s)", path, profile)); err != nil {
+			return nil, err
+		}
+		c.profile = profile
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+		if err := c.Set(s.name, value, SourceEnv, s.env); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.loadToken(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// loadToken reads the API token from the credentials file when it is not
+// set directly. The file named by api_token_file must exist; the default
+// credentials file next to the config file is optional
+func (c *Config) loadToken() error {
+	if c.APIToken != "" {
+		return nil
+	}
+
+	path := c.APITokenFile
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(dir, AppName, CredentialsFileName)
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+
+	token, err := readCredentials(path)
+	if err != nil {
+		return err
+	}
+	c.APIToken = token
+	c.sources["api_token"] = Setting{Name: "api_token", Env: "ABN_API_TOKEN", Source: SourceFile, Detail: path}
+	return nil
+}
+
+// readCredentials reads an API token from a file that only its owner can
+// read. Blank lines and lines starting with # are ignored
+func readCredentials(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read credentials file: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("credentials file %s must not be accessible by group or others (mode %04o), run: chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read credentials file: %v", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("credentials file %s has no token", path)
+}
+
+// applyValues applies settings read from the config file
+func (c *Config) applyValues(values map[string]interface{}, source, detail string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := c.Set(name, fileValue(values[name]), source, detail); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fileValue converts a value decoded from YAML to the string form Set accepts.
+// A key with no value, such as "audit_log:", is null in YAML and is taken
+// as empty
+func fileValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fileValue(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Set changes a setting by its config key and records where the value came
+// from
+func (c *Config) Set(name, value, source, detail string) error {
+	for _, s := range settings {
+		if s.name != name {
+			continue
+		}
+		if err := s.value(&c.Settings).Set(value); err != nil {
+			return fmt.Errorf("invalid %s %q from %s: %v", name, value, describe(source, detail), err)
+		}
+		c.sources[name] = Setting{Name: name, Env: s.env, Source: source, Detail: detail}
+		return nil
+	}
+	return fmt.Errorf("unknown setting %q from %s", name, describe(source, detail))
+}
+
+// Effective returns every setting with its current value and source
+func (c *Config) Effective() []Setting {
+	result := make([]Setting, len(settings))
+	for i, s := range settings {
+		setting := c.sources[s.name]
+		setting.Value = s.value(&c.Settings).String()
+		result[i] = setting
+	}
+	return result
+}
+
+// LoadedFile returns the config file that was read, or "" if there was none
+func (c *Config) LoadedFile() string {
+	return c.file
+}
+
+// Source returns where the setting with the given config key got its value
+func (c *Config) Source(name string) string {
+	return c.sources[name].Source
+}
+
+// ActiveProfile returns the profile that was applied, or "" if there was none
+func (c *Config) ActiveProfile() string {
+	return c.profile
+}
+
+func describe(source, detail string) string {
+	if detail == "" {
+		return source
+	}
+	return source + " " + detail
+}
+
+type stringValue string
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(value string) error {
+	*v = stringValue(value)
+	return nil
+}
+
+type positiveIntValue int
+
+func (v *positiveIntValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *positiveIntValue) Set(value string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("must be at least 1")
+	}
+	*v = positiveIntValue(n)
+	return nil
+}
+
+// durationValue accepts Go durations such as "90m" or "2h", or a plain
+// number of minutes to match the command-line flags
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+func (v *durationValue) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if minutes, err := strconv.Atoi(value); err == nil {
+		*v = durationValue(time.Duration(minutes) * time.Minute)
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) Set(value string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+// listValue holds a comma-separated list
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+func (v *listValue) Set(value string) error {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("must not be empty")
+	}
+	*v = items
+	return nil
+}
+
+// secretValue holds a credential. String never reveals it, so it is safe to
+// print and log
+type secretValue string
+
+func (v *secretValue) String() string {
+	if *v == "" {
+		return ""
+	}
+	return "********"
+}
+
+func (v *secretValue) Set(value string) error {
+	*v = secretValue(strings.TrimSpace(value))
+	return nil
+}
+
+// choiceValue accepts one of a fixed set of values, ignoring case
+type choiceValue struct {
+	value   *string
+	choices []string
+}
+
+func (v *choiceValue) String() string { return *v.value }
+
+func (v *choiceValue) Set(value string) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, choice := range v.choices {
+		if value == choice {
+			*v.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf("valid values are: %s", strings.Join(v.choices, ", "))
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package filelock
+
+import "os"
+
+// Lock is a no-op on platforms without file locking
+func Lock(file *os.File, exclusive bool) error {
+	return nil
+}
+
+// Unlock is a no-op on platforms without file locking
+func Unlock(file *os.File) error {
+	return nil
+}
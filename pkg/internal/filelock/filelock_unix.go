@@ -0,0 +1,27 @@
+//go:build unix
+
+package filelock
+
+import (
+	"os"
+	"syscall"
+)
+
+// Lock takes an advisory lock on file, blocking until it is available
+func Lock(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(file.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// Unlock releases a lock taken by Lock
+func Unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -1,45 +1,142 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
-	"path/filepath"
+	"io"
+	"log/slog"
 	"time"
 )
 
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
 var (
-	// Logger is the global logger instance
-	Logger *log.Logger
-	// LogFile is the file handle for the log file
-	file *os.File
+	// Logger is the global logger instance. It discards everything until
+	// Init is called
+	Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	// file is the log file being written, if any
+	file *rotatingFile
 )
 
-// Init initializes the logger with file output
-func Init(logDir string) error {
-	// Create logs directory if it doesn't exist
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %v", err)
-	}
+// Options configure where log records are written
+type Options struct {
+	// Dir is the directory log files are written to. No log file is
+	// written when it is empty
+	Dir string
+	// MaxSize is the size in bytes after which a new log file is started
+	MaxSize int64
+	// MaxAge is how long a log file is written to before a new one is
+	// started
+	MaxAge time.Duration
+	// MaxFiles is the number of log files kept in Dir
+	MaxFiles int
+	// RetainAge is how long after its last write a log file is kept
+	RetainAge time.Duration
 
-	// Create log file with timestamp
-	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	logFile := filepath.Join(logDir, fmt.Sprintf("api-%s.log", timestamp))
+	// Format is FormatText or FormatJSON
+	Format string
+	// Level is the lowest level written to the log file
+	Level slog.Level
+	// Stderr receives records at StderrLevel and above as well as the log
+	// file. Nothing is written to it when it is nil
+	Stderr      io.Writer
+	StderrLevel slog.Level
+}
 
-	var err error
-	file, err = os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %v", err)
+// Init initializes the logger with file output and, optionally, stderr
+// output. Log files are rotated and pruned according to opts
+func Init(opts Options) error {
+	if opts.Format != FormatText && opts.Format != FormatJSON {
+		return fmt.Errorf("invalid log format %q, valid values are: %s, %s", opts.Format, FormatText, FormatJSON)
+	}
+
+	var handlers fanout
+	if opts.Dir != "" {
+		var err error
+		file, err = openRotatingFile(opts)
+		if err != nil {
+			return err
+		}
+		handlers = append(handlers, newHandler(file, opts.Format, opts.Level, true))
+	}
+	if opts.Stderr != nil {
+		handlers = append(handlers, newHandler(opts.Stderr, opts.Format, opts.StderrLevel, false))
 	}
 
-	// Initialize logger with file output
-	Logger = log.New(file, "[API] ", log.Ldate|log.Ltime|log.Lshortfile)
+	Logger = slog.New(handlers)
 	return nil
 }
 
+// ParseLevel converts a level name such as "debug" or "warn" to a slog.Level
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, valid values are: debug, info, warn, error", name)
+	}
+	return level, nil
+}
+
 // Close closes the log file
 func Close() {
 	if file != nil {
 		file.Close()
 	}
 }
+
+// newHandler creates a handler writing records at level and above to w.
+// source adds the file and line that logged each record
+func newHandler(w io.Writer, format string, level slog.Level, source bool) slog.Handler {
+	opts := &slog.HandlerOptions{
+		AddSource: source,
+		Level:     level,
+	}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// fanout sends each record to every handler that accepts its level
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, h := range f {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := h.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
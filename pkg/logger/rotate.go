@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// filePrefix and fileSuffix surround the start time in log file names
+	filePrefix = "api-"
+	fileSuffix = ".log"
+	// fileTimeFormat is the layout of the start time in log file names
+	fileTimeFormat = "2006-01-02-15-04-05.000"
+	// legacyTimeFormat is the start time layout of files written before
+	// rotation was added
+	legacyTimeFormat = "2006-01-02-15-04-05"
+)
+
+// rotatingFile writes to the newest log file in a directory and starts a new
+// file once the current one reaches maxSize or is older than maxAge. Old
+// files beyond the retention limits are removed whenever a file is opened
+type rotatingFile struct {
+	dir       string
+	maxSize   int64
+	maxAge    time.Duration
+	maxFiles  int
+	retainAge time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time
+}
+
+// openRotatingFile opens the newest log file in opts.Dir if it is still
+// within the rotation limits, or starts a new one
+func openRotatingFile(opts Options) (*rotatingFile, error) {
+	// Create logs directory if it doesn't exist
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %v", err)
+	}
+
+	r := &rotatingFile{
+		dir:       opts.Dir,
+		maxSize:   opts.MaxSize,
+		maxAge:    opts.MaxAge,
+		maxFiles:  opts.MaxFiles,
+		retainAge: opts.RetainAge,
+	}
+
+	if files := r.logFiles(); len(files) > 0 {
+		newest := files[len(files)-1]
+		info, err := os.Stat(newest.path)
+		if err == nil && !r.due(info.Size(), newest.started, time.Now()) {
+			if err := r.open(newest.path, newest.started); err == nil {
+				r.cleanup()
+				return r, nil
+			}
+		}
+	}
+
+	if err := r.rotate(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.due(r.size+int64(len(p)), r.started, now) && r.size > 0 {
+		if err := r.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// due reports whether a file of the given size, started at started, should
+// be replaced by a new one
+func (r *rotatingFile) due(size int64, started, now time.Time) bool {
+	if r.maxSize > 0 && size > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && now.Sub(started) >= r.maxAge
+}
+
+// rotate closes the current file, starts a new one named after now and
+// applies the retention limits
+func (r *rotatingFile) rotate(now time.Time) error {
+	if r.file != nil {
+		r.file.Close()
+	}
+
+	name := filePrefix + now.Format(fileTimeFormat) + fileSuffix
+	if err := r.open(filepath.Join(r.dir, name), now); err != nil {
+		return err
+	}
+
+	r.cleanup()
+	return nil
+}
+
+func (r *rotatingFile) open(path string, started time.Time) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	r.file, r.size, r.started = file, size, started
+	return nil
+}
+
+// cleanup removes log files older than retainAge and the oldest files beyond
+// maxFiles. The current file is always kept
+func (r *rotatingFile) cleanup() {
+	files := r.logFiles()
+	current := r.file.Name()
+	now := time.Now()
+
+	for i, file := range files {
+		if file.path == current {
+			continue
+		}
+
+		tooMany := r.maxFiles > 0 && len(files)-i > r.maxFiles
+		tooOld := false
+		if r.retainAge > 0 {
+			if info, err := os.Stat(file.path); err == nil {
+				tooOld = now.Sub(info.ModTime()) > r.retainAge
+			}
+		}
+
+		if tooMany || tooOld {
+			os.Remove(file.path)
+		}
+	}
+}
+
+// logFile is a log file and the time it was started, from its name
+type logFile struct {
+	path    string
+	started time.Time
+}
+
+// logFiles returns the log files in the directory, oldest first
+func (r *rotatingFile) logFiles() []logFile {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []logFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix)
+		started, err := time.ParseInLocation(fileTimeFormat, stamp, time.Local)
+		if err != nil {
+			started, err = time.ParseInLocation(legacyTimeFormat, stamp, time.Local)
+		}
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{path: filepath.Join(r.dir, name), started: started})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].started.Before(files[j].started) })
+	return files
+}
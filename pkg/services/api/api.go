@@ -1,290 +1,491 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
 	"github.com/mohnish226/australian-business-data-api/pkg/config"
 	"github.com/mohnish226/australian-business-data-api/pkg/logger"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/audit"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/cache"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/ckan"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/normalize"
 )
 
-// Service handles API interactions
+// Service handles API interactions. A Service is safe for concurrent use;
+// its settings are fixed when it is created
 type Service struct {
-	client *http.Client
+	ckan      *ckan.Client
+	settings  config.Settings
+	cache     *cache.Cache
+	audit     *audit.Log
+	refreshes sync.WaitGroup
+	inflight  singleflight.Group
+
+	schemaMu  sync.Mutex
+	schema    *models.Schema
+	schemaErr error
 }
 
-// NewService creates a new API service instance
-func NewService() *Service {
-	return &Service{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+// NewService creates a new API service instance using the endpoints and
+// cache options from settings
+func NewService(settings config.Settings) *Service {
+	settings = settings.Clone()
+
+	client := ckan.NewClient(settings.Host, settings.APIToken, Endpoints(settings), nil)
+	err := client.SetBodyLogging(ckan.BodyLogging{
+		Mode:         settings.LogBodies,
+		Limit:        settings.LogBodyLimit,
+		RedactFields: settings.LogRedactFields,
+	})
+	if err != nil {
+		logger.Logger.Warn("Using default body logging", "error", err)
+	}
+	if config.APIKey != "" {
+		client.AddRedactor(func(s string) string {
+			return strings.ReplaceAll(s, config.APIKey, "[REDACTED]")
+		})
+	}
+
+	service := &Service{
+		ckan:     client,
+		settings: settings,
+		cache:    cache.New(settings),
+	}
+	if settings.AuditLog != "" {
+		service.audit = audit.New(settings.AuditLog)
 	}
+	return service
 }
 
-// BasicSearch performs a basic search using the datastore_search endpoint
-func (s *Service) BasicSearch(query string, filters map[string]string) ([]map[string]interface{}, error) {
-	logger.Logger.Printf("Starting basic search with query: %s, filters: %v", query, filters)
+// Endpoints returns the CKAN action paths from settings. The package and
+// resource actions sit alongside datastore_search
+func Endpoints(settings config.Settings) ckan.Endpoints {
+	endpoints := ckan.DefaultEndpoints(path.Dir(settings.RestPath))
+	endpoints.Search = settings.RestPath
+	endpoints.SearchSQL = settings.SQLPath
+	endpoints.Info = settings.InfoPath
+	return endpoints
+}
 
-	// Check cache first
-	if cached, err := cache.GetCache(query, filters); err == nil {
-		logger.Logger.Printf("Cache hit for query: %s", query)
-		// Safely convert cached data to []map[string]interface{}
-		jsonData, err := json.Marshal(cached)
-		if err != nil {
-			logger.Logger.Printf("Failed to marshal cached data: %v", err)
-			return nil, fmt.Errorf("failed to marshal cached data: %v", err)
-		}
+// CKAN returns the client the service uses for the portal
+func (s *Service) CKAN() *ckan.Client {
+	return s.ckan
+}
 
-		var result []map[string]interface{}
-		if err := json.Unmarshal(jsonData, &result); err != nil {
-			logger.Logger.Printf("Failed to unmarshal cached data: %v", err)
-			return nil, fmt.Errorf("failed to unmarshal cached data: %v", err)
-		}
+// Cache returns the cache used by the service
+func (s *Service) Cache() *cache.Cache {
+	return s.cache
+}
 
-		return result, nil
+// BasicSearch performs a basic search using the datastore_search endpoint.
+// The query and filters are normalised first, so equivalent searches share
+// the same request and cache entry. datastore_search only filters by
+// equality, so date range filters are applied to the records it returns
+func (s *Service) BasicSearch(query string, filters map[string]string) (*models.SearchResult, error) {
+	input := query
+	query = normalize.Query(query)
+	filters, err := normalize.Filters(filters)
+	if err != nil {
+		return nil, err
 	}
-	logger.Logger.Printf("Cache miss for query: %s", query)
 
-	// Build request body
-	requestBody := map[string]interface{}{
-		"resource_id": config.ResourceID,
-		"limit":       config.RequestLimit,
-		"offset":      0,
-		"q":           query,
-		"filters":     filters,
-	}
+	logger.Logger.Info("Starting basic search", "query", query, "input", input, "filters", filters, "endpoint", s.settings.RestPath)
 
-	// Convert request body to JSON
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		logger.Logger.Printf("Failed to marshal request body: %v", err)
-		return nil, fmt.Errorf("failed to marshal request body: %v", err)
-	}
+	equal, ranges := splitDateRanges(filters)
+	result, err := s.search(s.settings.RestPath, query, filters, func() ([]map[string]interface{}, bool, error) {
+		response, err := s.ckan.Search(ckan.SearchRequest{
+			ResourceID: s.settings.ResourceID,
+			Query:      query,
+			Filters:    equal,
+			Limit:      s.settings.RequestLimit,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		s.updateSchema(response.Fields)
+
+		records := response.Records
+		if len(ranges) > 0 {
+			records = filterRecords(records, ranges)
+		}
 
-	// Create request
-	url := fmt.Sprintf("%s%s", config.Host, config.RestPath)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+		// The response is complete when every matching record fit in one page
+		return records, response.Total >= 0 && response.Total <= len(response.Records), nil
+	})
+	s.recordAudit(s.settings.RestPath, query, filters, result, err)
 	if err != nil {
-		logger.Logger.Printf("Failed to create request: %v", err)
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, err
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
+	s.convertRecords(result)
+	result.Query = input
+	return result, nil
+}
 
-	// Make request
-	logger.Logger.Printf("Making POST request to: %s with body: %s", url, string(jsonBody))
-	resp, err := s.client.Do(req)
-	if err != nil {
-		logger.Logger.Printf("Request failed: %v", err)
-		return nil, fmt.Errorf("failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
+// SQLSearch performs a search using the datastore_search_sql endpoint
+func (s *Service) SQLSearch(query string) (*models.SearchResult, error) {
+	logger.Logger.Info("Starting SQL search", "query", query, "endpoint", s.settings.SQLPath)
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	result, err := s.search(s.settings.SQLPath, query, nil, func() ([]map[string]interface{}, bool, error) {
+		response, err := s.ckan.SearchSQL(s.settings.ResourceID, query)
+		if err != nil {
+			return nil, false, err
+		}
+		return response.Records, false, nil
+	})
+	s.recordAudit(s.settings.SQLPath, query, nil, result, err)
 	if err != nil {
-		logger.Logger.Printf("Failed to read response: %v", err)
-		return nil, fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
 
-	// Log raw response for debugging
-	logger.Logger.Printf("Raw response: %s", string(body))
+	s.convertRecords(result)
 
-	// Parse response
-	var searchResp models.SearchResponse
-	if err := json.Unmarshal(body, &searchResp); err != nil {
-		logger.Logger.Printf("Failed to parse response: %v", err)
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
+	result.Query = query
+	return result, nil
+}
 
-	// Extract records from response
-	records, ok := searchResp.Result["records"].([]interface{})
-	if !ok {
-		logger.Logger.Printf("Invalid response format: records not found in result: %+v", searchResp.Result)
-		return nil, fmt.Errorf("invalid response format: records not found")
+// recordAudit adds a search and its outcome to the audit log, if one is
+// configured. Failing to write the audit log does not fail the search
+func (s *Service) recordAudit(endpoint, query string, filters map[string]string, result *models.SearchResult, searchErr error) {
+	if s.audit == nil {
+		return
 	}
 
-	// Convert records to []map[string]interface{}
-	var result []map[string]interface{}
-	for _, record := range records {
-		// First convert to JSON and back to ensure proper type conversion
-		jsonData, err := json.Marshal(record)
-		if err != nil {
-			logger.Logger.Printf("Failed to marshal record: %v", err)
-			continue
-		}
-
-		var recordMap map[string]interface{}
-		if err := json.Unmarshal(jsonData, &recordMap); err != nil {
-			logger.Logger.Printf("Failed to unmarshal record: %v", err)
-			continue
+	entry := audit.Entry{
+		Query:      query,
+		Filters:    filters,
+		Endpoint:   endpoint,
+		ResourceID: s.settings.ResourceID,
+	}
+	if searchErr != nil {
+		entry.Cache = audit.CacheMiss
+		entry.Error = searchErr.Error()
+	} else {
+		entry.Records = len(result.Records)
+		switch {
+		case result.Derived:
+			entry.Cache = audit.CacheDerived
+		case result.Stale:
+			entry.Cache = audit.CacheStale
+		case result.FromCache:
+			entry.Cache = audit.CacheHit
+		default:
+			entry.Cache = audit.CacheMiss
 		}
-
-		result = append(result, recordMap)
 	}
 
-	logger.Logger.Printf("Found %d records for query: %s", len(result), query)
+	if err := s.audit.Record(entry); err != nil {
+		logger.Logger.Error("Failed to write audit log", "path", s.audit.Path(), "query", query, "error", err)
+	}
+}
 
-	// Cache the response
-	cache.SetCache(query, filters, result)
-	logger.Logger.Printf("Cached results for query: %s", query)
+// fetchFunc retrieves the records for a search from the API. complete
+// reports whether the records are every match rather than a single page
+type fetchFunc func() (records []map[string]interface{}, complete bool, err error)
 
-	return result, nil
+// Wait blocks until all background cache refreshes have finished
+func (s *Service) Wait() {
+	s.refreshes.Wait()
 }
 
-// SQLSearch performs a search using the datastore_search_sql endpoint
-func (s *Service) SQLSearch(query string) ([]map[string]interface{}, error) {
-	logger.Logger.Printf("Starting SQL search with query: %s", query)
+// search serves a query from the cache when possible and calls fetch
+// otherwise. Narrower searches are answered from a complete cached result
+// for the same query with fewer filters or wider date ranges. Stale cache
+// entries are returned straight away and refreshed in the background when
+// Settings.StaleWhileRevalidate is set, and are used as a fallback when
+// fetch fails. With Settings.CacheRefresh the cache is not read and every
+// search is fetched
+func (s *Service) search(endpoint, query string, filters map[string]string, fetch fetchFunc) (*models.SearchResult, error) {
+	if s.settings.CacheRefresh {
+		logger.Logger.Info("Refreshing cache entry", "query", query, "filters", filters, "endpoint", endpoint)
+		records, err := s.fetchAndCache(endpoint, query, filters, fetch)
+		if err != nil {
+			return nil, err
+		}
+		return &models.SearchResult{Records: records, FetchedAt: time.Now()}, nil
+	}
 
 	// Check cache first
-	if cached, err := cache.GetCache(query, nil); err == nil {
-		logger.Logger.Printf("Cache hit for SQL query: %s", query)
-		// Safely convert cached data to []map[string]interface{}
-		jsonData, err := json.Marshal(cached)
-		if err != nil {
-			logger.Logger.Printf("Failed to marshal cached data: %v", err)
-			return nil, fmt.Errorf("failed to marshal cached data: %v", err)
+	entry, stale, err := s.cache.Lookup(query, filters)
+	if err != nil {
+		if derived, err := s.derivedResult(query, filters); err == nil {
+			return derived, nil
 		}
 
-		var result []map[string]interface{}
-		if err := json.Unmarshal(jsonData, &result); err != nil {
-			logger.Logger.Printf("Failed to unmarshal cached data: %v", err)
-			return nil, fmt.Errorf("failed to unmarshal cached data: %v", err)
+		logger.Logger.Info("Cache miss", "query", query, "filters", filters, "endpoint", endpoint)
+
+		records, err := s.fetchAndCache(endpoint, query, filters, fetch)
+		if err != nil {
+			return nil, err
 		}
+		return &models.SearchResult{Records: records, FetchedAt: time.Now()}, nil
+	}
 
-		return result, nil
+	records, err := cachedRecords(entry.Data)
+	if err != nil {
+		return nil, err
 	}
-	logger.Logger.Printf("Cache miss for SQL query: %s", query)
 
-	// Build request body
-	requestBody := map[string]interface{}{
-		"resource_id": config.ResourceID,
-		"sql":         query,
+	cached := &models.SearchResult{
+		Records:   records,
+		FromCache: true,
+		Stale:     stale,
+		FetchedAt: entry.Timestamp,
 	}
 
-	// Convert request body to JSON
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		logger.Logger.Printf("Failed to marshal SQL request body: %v", err)
-		return nil, fmt.Errorf("failed to marshal request body: %v", err)
+	if !stale {
+		logger.Logger.Info("Cache hit", "query", query, "filters", filters, "endpoint", endpoint, "records", len(records), "age", cached.Age().Round(time.Second))
+		return cached, nil
 	}
 
-	// Create request
-	url := fmt.Sprintf("%s%s", config.Host, config.SQLPath)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	if s.settings.StaleWhileRevalidate {
+		logger.Logger.Info("Serving stale cache, refreshing in background", "query", query, "filters", filters, "endpoint", endpoint, "records", len(records), "age", cached.Age().Round(time.Second))
+		s.refresh(endpoint, query, filters, fetch)
+		return cached, nil
+	}
+
+	logger.Logger.Info("Cache entry is stale, refreshing", "query", query, "filters", filters, "endpoint", endpoint, "age", cached.Age().Round(time.Second))
+	records, err = s.fetchAndCache(endpoint, query, filters, fetch)
 	if err != nil {
-		logger.Logger.Printf("Failed to create SQL request: %v", err)
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		logger.Logger.Warn("Refresh failed, serving stale cache", "query", query, "filters", filters, "endpoint", endpoint, "records", len(cached.Records), "error", err)
+		cached.RefreshErr = err
+		return cached, nil
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
+	return &models.SearchResult{Records: records, FetchedAt: time.Now()}, nil
+}
+
+// refresh re-fetches a query in the background and updates the cache
+func (s *Service) refresh(endpoint, query string, filters map[string]string, fetch fetchFunc) {
+	s.refreshes.Add(1)
+	go func() {
+		defer s.refreshes.Done()
+		if _, err := s.fetchAndCache(endpoint, query, filters, fetch); err != nil {
+			logger.Logger.Warn("Background refresh failed", "query", query, "filters", filters, "endpoint", endpoint, "error", err)
+			return
+		}
+		logger.Logger.Info("Background refresh completed", "query", query, "filters", filters, "endpoint", endpoint)
+	}()
+}
 
-	// Make request
-	logger.Logger.Printf("Making SQL POST request to: %s with body: %s", url, string(jsonBody))
-	resp, err := s.client.Do(req)
+// derivedResult answers a search by filtering a complete cached result for
+// the same query with fewer filters or wider date ranges
+func (s *Service) derivedResult(query string, filters map[string]string) (*models.SearchResult, error) {
+	entry, err := s.cache.FindCovering(query, filters)
 	if err != nil {
-		logger.Logger.Printf("SQL request failed: %v", err)
-		return nil, fmt.Errorf("failed to make request: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	records, err := cachedRecords(entry.Data)
 	if err != nil {
-		logger.Logger.Printf("Failed to read SQL response: %v", err)
-		return nil, fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
 
-	// Log raw response for debugging
-	logger.Logger.Printf("Raw SQL response: %s", string(body))
+	result := filterRecords(records, filters)
 
-	// Parse response
-	var searchResp models.SearchResponse
-	if err := json.Unmarshal(body, &searchResp); err != nil {
-		logger.Logger.Printf("Failed to parse SQL response: %v", err)
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
+	logger.Logger.Info("Derived result from cached broader search", "query", query, "filters", filters,
+		"cached_filters", entry.Filters, "records", len(result), "cached_records", len(records))
 
-	// Extract records from response
-	records, ok := searchResp.Result["records"].([]interface{})
-	if !ok {
-		logger.Logger.Printf("Invalid SQL response format: records not found in result: %+v", searchResp.Result)
-		return nil, fmt.Errorf("invalid response format: records not found")
-	}
+	return &models.SearchResult{
+		Records:   result,
+		FromCache: true,
+		Derived:   true,
+		FetchedAt: entry.Timestamp,
+	}, nil
+}
 
-	// Convert records to []map[string]interface{}
+// filterRecords returns the records that match filters
+func filterRecords(records []map[string]interface{}, filters map[string]string) []map[string]interface{} {
 	var result []map[string]interface{}
 	for _, record := range records {
-		// First convert to JSON and back to ensure proper type conversion
-		jsonData, err := json.Marshal(record)
-		if err != nil {
-			logger.Logger.Printf("Failed to marshal record: %v", err)
-			continue
+		if matchesFilters(record, filters) {
+			result = append(result, record)
 		}
+	}
+	return result
+}
 
-		var recordMap map[string]interface{}
-		if err := json.Unmarshal(jsonData, &recordMap); err != nil {
-			logger.Logger.Printf("Failed to unmarshal record: %v", err)
+// matchesFilters reports whether a record has every filter value. For a
+// date range filter the record's date must fall within the range
+func matchesFilters(record map[string]interface{}, filters map[string]string) bool {
+	for k, v := range filters {
+		value, ok := record[k]
+		if !ok {
+			return false
+		}
+		if r, isRange := models.ParseDateRange(v); isRange {
+			date, ok := models.ParseDate(fmt.Sprintf("%v", value))
+			if !ok || !r.Contains(date) {
+				return false
+			}
 			continue
 		}
+		if fmt.Sprintf("%v", value) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// splitDateRanges separates the date range filters in filters from the
+// equality filters
+func splitDateRanges(filters map[string]string) (equal, ranges map[string]string) {
+	equal = make(map[string]string, len(filters))
+	ranges = make(map[string]string)
+	for k, v := range filters {
+		if _, isRange := models.ParseDateRange(v); isRange {
+			ranges[k] = v
+		} else {
+			equal[k] = v
+		}
+	}
+	return equal, ranges
+}
+
+// fetchAndCache calls fetch and stores its records in the cache. Identical
+// requests made while one is already in flight wait for it and share its
+// records instead of calling the API again
+func (s *Service) fetchAndCache(endpoint, query string, filters map[string]string, fetch fetchFunc) ([]map[string]interface{}, error) {
+	key := requestKey(endpoint, query, filters)
+
+	value, err, shared := s.inflight.Do(key, func() (interface{}, error) {
+		start := time.Now()
+		records, complete, err := fetch()
+		if err != nil {
+			logger.Logger.Error("Search request failed", "query", query, "filters", filters, "endpoint", endpoint, "duration", time.Since(start), "error", err)
+			return nil, err
+		}
+
+		logger.Logger.Info("Search request completed", "query", query, "filters", filters, "endpoint", endpoint,
+			"records", len(records), "complete", complete, "duration", time.Since(start))
+
+		// Cache the response
+		store := s.cache.Set
+		if complete {
+			store = s.cache.SetComplete
+		}
+		if err := store(query, filters, records); err != nil {
+			logger.Logger.Warn("Failed to cache results", "query", query, "filters", filters, "error", err)
+		} else {
+			logger.Logger.Debug("Cached results", "query", query, "filters", filters, "records", len(records))
+		}
+
+		return records, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := value.([]map[string]interface{})
+	if shared {
+		// Callers may modify their records, so each one gets its own copy
+		logger.Logger.Info("Shared in-flight request", "query", query, "filters", filters, "endpoint", endpoint)
+		records = copyRecords(records)
+	}
+
+	return records, nil
+}
+
+// requestKey identifies a request by its endpoint, query and filters,
+// independent of the order of the filters
+func requestKey(endpoint, query string, filters map[string]string) string {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(endpoint)
+	b.WriteByte(0)
+	b.WriteString(query)
+	for _, k := range keys {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(filters[k])
+	}
+	return b.String()
+}
+
+// copyRecords returns a copy of records that shares no maps with it
+func copyRecords(records []map[string]interface{}) []map[string]interface{} {
+	if records == nil {
+		return nil
+	}
 
-		result = append(result, recordMap)
+	result := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		result[i] = make(map[string]interface{}, len(record))
+		for k, v := range record {
+			result[i][k] = v
+		}
 	}
+	return result
+}
 
-	logger.Logger.Printf("Found %d records for SQL query: %s", len(result), query)
+// cachedRecords converts cached data to []map[string]interface{}
+func cachedRecords(cached interface{}) ([]map[string]interface{}, error) {
+	jsonData, err := json.Marshal(cached)
+	if err != nil {
+		logger.Logger.Error("Failed to marshal cached data", "error", err)
+		return nil, fmt.Errorf("failed to marshal cached data: %v", err)
+	}
 
-	// Cache the response
-	cache.SetCache(query, nil, result)
-	logger.Logger.Printf("Cached SQL results for query: %s", query)
+	var result []map[string]interface{}
+	if err := json.Unmarshal(jsonData, &result); err != nil {
+		logger.Logger.Error("Failed to unmarshal cached data", "error", err)
+		return nil, fmt.Errorf("failed to unmarshal cached data: %v", err)
+	}
 
 	return result, nil
 }
 
 // GetBusinesses retrieves business data using the specified search method
 func (s *Service) GetBusinesses(query string, filters map[string]string, useSQL bool) ([]models.Business, error) {
-	logger.Logger.Printf("Getting businesses with query: %s, filters: %v, useSQL: %v", query, filters, useSQL)
+	logger.Logger.Info("Getting businesses", "query", query, "filters", filters, "sql", useSQL)
 
-	var records []map[string]interface{}
+	var result *models.SearchResult
 	var err error
 
 	if useSQL {
-		records, err = s.SQLSearch(query)
+		result, err = s.SQLSearch(query)
 	} else {
-		records, err = s.BasicSearch(query, filters)
+		result, err = s.BasicSearch(query, filters)
 	}
 
 	if err != nil {
-		logger.Logger.Printf("Failed to get businesses: %v", err)
+		logger.Logger.Error("Failed to get businesses", "query", query, "filters", filters, "error", err)
 		return nil, err
 	}
 
 	// Convert records to Business structs
 	var businesses []models.Business
-	for _, record := range records {
+	for _, record := range result.Records {
 		jsonData, err := json.Marshal(record)
 		if err != nil {
-			logger.Logger.Printf("Failed to marshal record: %v", err)
+			logger.Logger.Warn("Failed to marshal record", "error", err)
 			continue
 		}
 
 		var business models.Business
 		if err := json.Unmarshal(jsonData, &business); err != nil {
-			logger.Logger.Printf("Failed to unmarshal business: %v", err)
+			logger.Logger.Warn("Failed to unmarshal business", "error", err)
 			continue
 		}
 
 		businesses = append(businesses, business)
 	}
 
-	logger.Logger.Printf("Successfully converted %d records to businesses", len(businesses))
+	logger.Logger.Debug("Converted records to businesses", "records", len(businesses))
 	return businesses, nil
 }
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/output"
+)
+
+// newTestServer starts a CKAN portal that answers datastore_search with two
+// records after release is closed, and counts the search requests it gets
+func newTestServer(t *testing.T, release <-chan struct{}) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var searches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != config.DefaultRestPath {
+			http.NotFound(w, r)
+			return
+		}
+		searches.Add(1)
+		<-release
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"result": map[string]interface{}{
+				"fields": []map[string]string{
+					{"id": "BN_NAME", "type": "text"},
+					{"id": "BN_STATE_OF_REG", "type": "text"},
+				},
+				"records": []map[string]interface{}{
+					{"BN_NAME": "ACME PTY LTD", "BN_STATE_OF_REG": "NSW"},
+					{"BN_NAME": "ACME HOLDINGS", "BN_STATE_OF_REG": "NSW"},
+				},
+				"total": 2,
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &searches
+}
+
+// testSettings returns settings that send requests to host and keep the
+// cache in a temporary directory, with no audit log
+func testSettings(t *testing.T, host string) config.Settings {
+	settings := config.Default()
+	settings.Host = host
+	settings.CacheDir = t.TempDir()
+	settings.AuditLog = ""
+	return settings
+}
+
+func TestBasicSearchCoalescesConcurrentRequests(t *testing.T) {
+	const callers = 20
+
+	release := make(chan struct{})
+	server, searches := newTestServer(t, release)
+	service := NewService(testSettings(t, server.URL))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	counts := make(chan int, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := service.BasicSearch("acme", map[string]string{"BN_STATE_OF_REG": "NSW"})
+			if err != nil {
+				errs <- err
+				return
+			}
+			counts <- len(result.Records)
+			// Each caller gets its own records, so changing them is safe
+			result.Records[0]["BN_NAME"] = "changed"
+		}()
+	}
+
+	// Hold the first request until every caller has had time to join it
+	time.Sleep(200 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	service.Wait()
+	close(errs)
+	close(counts)
+
+	for err := range errs {
+		t.Fatalf("BasicSearch failed: %v", err)
+	}
+	for n := range counts {
+		if n != 2 {
+			t.Errorf("got %d records, want 2", n)
+		}
+	}
+	if n := searches.Load(); n != 1 {
+		t.Errorf("%d concurrent callers made %d search requests, want 1", callers, n)
+	}
+}
+
+func TestServicesWithDifferentSettings(t *testing.T) {
+	const services = 8
+
+	release := make(chan struct{})
+	close(release)
+	server, _ := newTestServer(t, release)
+
+	headers := [][]string{
+		{"BN_NAME"},
+		{"BN_STATE_OF_REG"},
+		{"BN_NAME", "BN_STATE_OF_REG"},
+		{"BN_STATE_OF_REG", "BN_NAME"},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, services)
+	for i := 0; i < services; i++ {
+		settings := testSettings(t, server.URL)
+		settings.CacheExpiration = time.Duration(i+1) * time.Hour
+		settings.Headers = headers[i%len(headers)]
+
+		wg.Add(1)
+		go func(i int, settings config.Settings) {
+			defer wg.Done()
+			errs <- checkServiceSettings(settings, fmt.Sprintf("acme %d", i%2))
+		}(i, settings)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// checkServiceSettings runs a search with its own service and checks that
+// the cache entry and output columns follow settings
+func checkServiceSettings(settings config.Settings, query string) error {
+	service := NewService(settings)
+	defer service.Wait()
+
+	var result *models.SearchResult
+	for i := 0; i < 5; i++ {
+		var err error
+		if result, err = service.BasicSearch(query, nil); err != nil {
+			return err
+		}
+	}
+
+	if dir := service.Cache().Dir(); dir != settings.CacheDir {
+		return fmt.Errorf("cache dir is %s, want %s", dir, settings.CacheDir)
+	}
+	infos, err := service.Cache().List()
+	if err != nil {
+		return err
+	}
+	if len(infos) != 1 {
+		return fmt.Errorf("%s has %d cache entries, want 1", settings.CacheDir, len(infos))
+	}
+	if got := infos[0].Expiration.Sub(infos[0].Timestamp).Round(time.Second); got != settings.CacheExpiration {
+		return fmt.Errorf("cache entry in %s expires after %s, want %s", settings.CacheDir, got, settings.CacheExpiration)
+	}
+
+	columns, err := output.Columns(result.Records, settings.Headers, service.DefaultSchema())
+	if err != nil {
+		return err
+	}
+	if len(columns) != len(settings.Headers) {
+		return fmt.Errorf("got %d columns, want %v", len(columns), settings.Headers)
+	}
+	for i, column := range columns {
+		if column.Field != settings.Headers[i] {
+			return fmt.Errorf("column %d is %s, want %s", i, column.Field, settings.Headers[i])
+		}
+	}
+	return nil
+}
+
+func TestBasicSearchNarrowsCachedDateRange(t *testing.T) {
+	var searches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != config.DefaultRestPath {
+			http.NotFound(w, r)
+			return
+		}
+		searches.Add(1)
+
+		var body struct {
+			Filters map[string]string `json:"filters"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if _, ok := body.Filters["BN_REG_DT"]; ok {
+			t.Errorf("date range sent to datastore_search: %v", body.Filters)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"result": map[string]interface{}{
+				"records": []map[string]interface{}{
+					{"BN_NAME": "ACME 2009", "BN_REG_DT": "15/06/2009"},
+					{"BN_NAME": "ACME 2012", "BN_REG_DT": "01/02/2012"},
+					{"BN_NAME": "ACME 2016", "BN_REG_DT": "31/12/2016"},
+					{"BN_NAME": "ACME 2019", "BN_REG_DT": "2019-07-04"},
+				},
+				"total": 4,
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	service := NewService(testSettings(t, server.URL))
+	defer service.Wait()
+
+	tests := []struct {
+		name     string
+		dates    string
+		want     []string
+		derived  bool
+		searches int32
+	}{
+		{"range is fetched", "01/01/2010..31/12/2018", []string{"ACME 2012", "ACME 2016"}, false, 1},
+		{"tighter range is derived", "2016-01-01..2016-12-31", []string{"ACME 2016"}, true, 1},
+		{"open end inside range is not covered", "2012-01-01..", []string{"ACME 2012", "ACME 2016", "ACME 2019"}, false, 2},
+		{"single day is derived", "2012-02-01", []string{"ACME 2012"}, true, 2},
+		{"wider range is fetched", "2005-01-01..2018-12-31", []string{"ACME 2009", "ACME 2012", "ACME 2016"}, false, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.BasicSearch("acme", map[string]string{"BN_REG_DT": tt.dates})
+			if err != nil {
+				t.Fatalf("BasicSearch failed: %v", err)
+			}
+
+			var names []string
+			for _, record := range result.Records {
+				names = append(names, record["BN_NAME"].(string))
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Errorf("got records %v, want %v", names, tt.want)
+			}
+			if result.Derived != tt.derived {
+				t.Errorf("Derived is %v, want %v", result.Derived, tt.derived)
+			}
+			if n := searches.Load(); n != tt.searches {
+				t.Errorf("%d search requests made, want %d", n, tt.searches)
+			}
+		})
+	}
+}
+
+func TestCacheHitDoesNotFetchSchema(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server, _ := newTestServer(t, release)
+	settings := testSettings(t, server.URL)
+	if _, err := NewService(settings).BasicSearch("acme", nil); err != nil {
+		t.Fatalf("BasicSearch failed: %v", err)
+	}
+
+	// Without a stored schema, a cache hit still makes no request
+	if err := os.RemoveAll(filepath.Join(settings.CacheDir, "schema")); err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	offline := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(offline.Close)
+	settings.Host = offline.URL
+
+	service := NewService(settings)
+	result, err := service.BasicSearch("acme", nil)
+	if err != nil {
+		t.Fatalf("BasicSearch failed: %v", err)
+	}
+	if !result.FromCache || len(result.Records) != 2 {
+		t.Errorf("got %d records, from cache %v; want 2 from the cache", len(result.Records), result.FromCache)
+	}
+	if schema := service.KnownSchema(); len(schema.Fields) == 0 {
+		t.Error("KnownSchema has no fields")
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("cache hit made %d requests, want 0", n)
+	}
+}
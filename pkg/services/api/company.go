@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+const (
+	// BusinessNamesField is the column LinkBusinessNames adds to company
+	// records
+	BusinessNamesField = "Business Names"
+
+	// companyABNField is the company register's ABN column
+	companyABNField = "ABN"
+
+	// linkConcurrency is the number of ABNs looked up at the same time
+	linkConcurrency = 4
+)
+
+// GetCompanies searches the ASIC company register. The service must be
+// created with settings from config.Settings.Companies
+func (s *Service) GetCompanies(query string, filters map[string]string) ([]models.Company, error) {
+	logger.Logger.Info("Getting companies", "query", query, "filters", filters)
+
+	result, err := s.BasicSearch(query, filters)
+	if err != nil {
+		logger.Logger.Error("Failed to get companies", "query", query, "filters", filters, "error", err)
+		return nil, err
+	}
+
+	// Convert records to Company structs
+	var companies []models.Company
+	for _, record := range result.Records {
+		jsonData, err := json.Marshal(textRecord(record))
+		if err != nil {
+			logger.Logger.Warn("Failed to marshal record", "error", err)
+			continue
+		}
+
+		var company models.Company
+		if err := json.Unmarshal(jsonData, &company); err != nil {
+			logger.Logger.Warn("Failed to unmarshal company", "error", err)
+			continue
+		}
+
+		companies = append(companies, company)
+	}
+
+	logger.Logger.Debug("Converted records to companies", "records", len(companies))
+	return companies, nil
+}
+
+// BusinessNamesByABN returns the business names registered to each ABN. The
+// service must search the business names dataset. Names found before an
+// error are returned along with it
+func (s *Service) BusinessNamesByABN(abns []string) (map[string][]string, error) {
+	names := map[string][]string{}
+	var mu sync.Mutex
+	var firstErr error
+
+	sem := make(chan struct{}, linkConcurrency)
+	var wg sync.WaitGroup
+	for _, abn := range uniqueABNs(abns) {
+		wg.Add(1)
+		go func(abn string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, err := s.BasicSearch("", map[string]string{"BN_ABN": abn})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Logger.Warn("Failed to find business names", "abn", abn, "error", err)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to find business names for ABN %s: %v", abn, err)
+				}
+				return
+			}
+			for _, record := range result.Records {
+				if name, ok := record["BN_NAME"].(string); ok && name != "" {
+					names[abn] = append(names[abn], name)
+				}
+			}
+			sort.Strings(names[abn])
+		}(abn)
+	}
+	wg.Wait()
+
+	return names, firstErr
+}
+
+// LinkBusinessNames adds the business names registered to each company's ABN
+// to its record, joined with "; " in the BusinessNamesField column.
+// businessNames is a service for the business names dataset
+func LinkBusinessNames(records []map[string]interface{}, businessNames *Service) error {
+	abns := make([]string, 0, len(records))
+	for _, record := range records {
+		abns = append(abns, recordABN(record))
+	}
+
+	names, err := businessNames.BusinessNamesByABN(abns)
+	for _, record := range records {
+		record[BusinessNamesField] = strings.Join(names[recordABN(record)], "; ")
+	}
+
+	logger.Logger.Info("Linked business names", "records", len(records), "abns", len(names))
+	return err
+}
+
+// LinkCompanyBusinessNames sets the BusinessNames of each company to the
+// business names registered to its ABN. businessNames is a service for the
+// business names dataset
+func LinkCompanyBusinessNames(companies []models.Company, businessNames *Service) error {
+	abns := make([]string, len(companies))
+	for i, company := range companies {
+		abns[i] = recordABN(map[string]interface{}{companyABNField: company.ABN})
+	}
+
+	names, err := businessNames.BusinessNamesByABN(abns)
+	for i := range companies {
+		companies[i].BusinessNames = names[abns[i]]
+	}
+	return err
+}
+
+// recordABN returns a company record's ABN without spaces, or "" if it has
+// none
+func recordABN(record map[string]interface{}) string {
+	value, ok := record[companyABNField]
+	if !ok || value == nil {
+		return ""
+	}
+	abn := strings.ReplaceAll(fmt.Sprintf("%v", value), " ", "")
+	if strings.Trim(abn, "0") == "" {
+		return ""
+	}
+	return abn
+}
+
+// uniqueABNs returns the distinct non-empty ABNs, sorted
+func uniqueABNs(abns []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, abn := range abns {
+		if abn == "" || seen[abn] {
+			continue
+		}
+		seen[abn] = true
+		result = append(result, abn)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// textRecord returns a copy of record with every scalar value as a string,
+// so values the schema converted to numbers still decode into string fields
+func textRecord(record map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		switch v.(type) {
+		case nil, string, []interface{}, []string:
+			result[k] = v
+		default:
+			result[k] = fmt.Sprintf("%v", v)
+		}
+	}
+	return result
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // SearchResponse represents the API response structure
 type SearchResponse struct {
 	Help    string                 `json:"help"`
@@ -16,3 +18,27 @@ type SearchParams struct {
 	Limit      int
 	UseCache   bool
 }
+
+// SearchResult holds the records returned by a search along with where
+// they came from
+type SearchResult struct {
+	// Query is the search term as it was entered, before normalisation
+	Query   string
+	Records []map[string]interface{}
+	// FromCache is true when the records were served from the cache
+	FromCache bool
+	// Stale is true when the records come from an expired cache entry
+	Stale bool
+	// Derived is true when the records were filtered from a cached result
+	// for a broader search
+	Derived bool
+	// FetchedAt is when the records were retrieved from the API
+	FetchedAt time.Time
+	// RefreshErr is the error that caused stale records to be served
+	RefreshErr error
+}
+
+// Age returns how long ago the records were retrieved from the API
+func (r *SearchResult) Age() time.Duration {
+	return time.Since(r.FetchedAt)
+}
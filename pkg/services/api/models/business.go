@@ -26,3 +26,24 @@ type BusinessStats struct {
 	RegistrationByState  map[string]int
 	RegistrationByStatus map[string]int
 }
+
+// Company represents a company in the ASIC company register
+type Company struct {
+	Name                    string `json:"Company Name"`
+	ACN                     string `json:"ACN"`
+	Type                    string `json:"Type"`
+	Class                   string `json:"Class"`
+	SubClass                string `json:"Sub Class"`
+	Status                  string `json:"Status"`
+	RegistrationDate        string `json:"Date of Registration"`
+	DeregistrationDate      string `json:"Date of Deregistration"`
+	PreviousStateOfReg      string `json:"Previous State of Registration"`
+	StateRegistrationNumber string `json:"State Registration number"`
+	CurrentNameIndicator    string `json:"Current Name Indicator"`
+	ABN                     string `json:"ABN"`
+	CurrentName             string `json:"Current Name"`
+	CurrentNameStartDate    string `json:"Current Name Start Date"`
+	// BusinessNames are the business names registered to the company's ABN,
+	// when they have been linked
+	BusinessNames []string `json:"Business Names,omitempty"`
+}
@@ -4,7 +4,33 @@ import "time"
 
 // CacheEntry represents a cached API response
 type CacheEntry struct {
+	Query   string            `json:"query,omitempty"`
+	Filters map[string]string `json:"filters,omitempty"`
+	// Complete is true when Data holds every record matching Query and
+	// Filters, so narrower searches can be answered from it
+	Complete   bool        `json:"complete,omitempty"`
 	Data       interface{} `json:"data"`
 	Timestamp  time.Time   `json:"timestamp"`
 	Expiration time.Time   `json:"expiration"`
 }
+
+// CacheInfo describes a cache entry on disk without its payload
+type CacheInfo struct {
+	Key        string            `json:"key"`
+	Query      string            `json:"query"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Expiration time.Time         `json:"expiration"`
+	Size       int64             `json:"size"`
+	Records    int               `json:"records"`
+}
+
+// Expired reports whether the entry is past its expiration time
+func (c CacheInfo) Expired() bool {
+	return time.Now().After(c.Expiration)
+}
+
+// Age returns how long ago the entry was written
+func (c CacheInfo) Age() time.Duration {
+	return time.Since(c.Timestamp)
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// dateLayouts are the date formats found in the datasets, the first being
+// the DD/MM/YYYY format both registers use
+var dateLayouts = []string{
+	"02/01/2006",
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// ParseDate parses a date in any of the formats used by the datasets
+func ParseDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// DateRangeSeparator separates the first and last dates of a date range
+// filter, such as "2020-01-01..2020-12-31"
+const DateRangeSeparator = ".."
+
+// DateRange is an inclusive range of days used as a filter value. A zero
+// From or To leaves that end of the range open
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParseDateRange parses a filter value of the form FROM..TO, where either
+// date may be left out. ok is false when value is not a date range
+func ParseDateRange(value string) (r DateRange, ok bool) {
+	from, to, found := strings.Cut(value, DateRangeSeparator)
+	if !found {
+		return DateRange{}, false
+	}
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" && to == "" {
+		return DateRange{}, false
+	}
+
+	if from != "" {
+		if r.From, ok = ParseDate(from); !ok {
+			return DateRange{}, false
+		}
+	}
+	if to != "" {
+		if r.To, ok = ParseDate(to); !ok {
+			return DateRange{}, false
+		}
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && r.To.Before(r.From) {
+		return DateRange{}, false
+	}
+	return r, true
+}
+
+// String returns the range as a filter value with ISO 8601 dates
+func (r DateRange) String() string {
+	var from, to string
+	if !r.From.IsZero() {
+		from = r.From.Format("2006-01-02")
+	}
+	if !r.To.IsZero() {
+		to = r.To.Format("2006-01-02")
+	}
+	return from + DateRangeSeparator + to
+}
+
+// Contains reports whether t falls on a day within the range
+func (r DateRange) Contains(t time.Time) bool {
+	if !r.From.IsZero() && t.Before(r.From) {
+		return false
+	}
+	return r.To.IsZero() || t.Before(r.To.AddDate(0, 0, 1))
+}
+
+// Covers reports whether every day in other is also in r
+func (r DateRange) Covers(other DateRange) bool {
+	if !r.From.IsZero() && (other.From.IsZero() || other.From.Before(r.From)) {
+		return false
+	}
+	if !r.To.IsZero() && (other.To.IsZero() || other.To.After(r.To)) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field describes one column of a datastore resource
+type Field struct {
+	ID string `json:"id"`
+	// Type is the datastore column type, such as text, int4 or numeric
+	Type string `json:"type"`
+	// Label is the column's display name, if the portal provides one
+	Label string `json:"label,omitempty"`
+}
+
+// Schema lists the fields of a datastore resource
+type Schema struct {
+	ResourceID string    `json:"resource_id"`
+	Fields     []Field   `json:"fields"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+// Field returns the field with the given id. A nil Schema has no fields
+func (s *Schema) Field(id string) (Field, bool) {
+	if s == nil {
+		return Field{}, false
+	}
+	for _, field := range s.Fields {
+		if field.ID == id {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Lookup finds a field by its id or label, ignoring case. A nil Schema has
+// no fields
+func (s *Schema) Lookup(name string) (Field, bool) {
+	if s == nil {
+		return Field{}, false
+	}
+	name = strings.TrimSpace(name)
+	for _, field := range s.Fields {
+		if strings.EqualFold(field.ID, name) || (field.Label != "" && strings.EqualFold(field.Label, name)) {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// Missing returns the ids that are not fields of the schema
+func (s *Schema) Missing(ids []string) []string {
+	var missing []string
+	for _, id := range ids {
+		if _, ok := s.Field(id); !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// Convert changes the values of numeric and boolean fields in record to Go
+// numbers and booleans. Whole numbers become int64. Values that do not parse
+// and identifiers with leading zeros, such as ACNs, are left unchanged
+func (s *Schema) Convert(record map[string]interface{}) {
+	for _, field := range s.Fields {
+		value, ok := record[field.ID]
+		if !ok || value == nil {
+			continue
+		}
+
+		switch field.Type {
+		case "int", "int2", "int4", "int8", "integer", "bigint", "smallint":
+			switch v := value.(type) {
+			case string:
+				if n, ok := parseInt(v); ok {
+					record[field.ID] = n
+				}
+			case float64:
+				if v == math.Trunc(v) {
+					record[field.ID] = int64(v)
+				}
+			}
+		case "numeric", "float", "float4", "float8", "real", "double precision":
+			if v, ok := value.(string); ok {
+				if n, ok := parseInt(v); ok {
+					record[field.ID] = n
+				} else if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && !hasLeadingZero(v) {
+					record[field.ID] = n
+				}
+			}
+		case "bool", "boolean":
+			if v, ok := value.(string); ok {
+				if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+					record[field.ID] = b
+				}
+			}
+		}
+	}
+}
+
+// parseInt parses a whole number that has no leading zeros
+func parseInt(s string) (int64, bool) {
+	if hasLeadingZero(s) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	return n, err == nil
+}
+
+// hasLeadingZero reports whether s is a number written with a leading zero,
+// such as "0123", which is an identifier rather than a quantity
+func hasLeadingZero(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) > 1 && s[0] == '0' && s[1] != '.'
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"slices"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// schemaMaxAge is how long a stored schema is used before datastore_info is
+// called again
+const schemaMaxAge = 24 * time.Hour
+
+// Schema returns the fields of the datastore resource. It comes from the
+// fields of the last search response or from datastore_info, and is kept in
+// the cache for a day. An older stored schema is used when the portal cannot
+// be reached
+func (s *Service) Schema() (*models.Schema, error) {
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	if s.schema != nil {
+		return s.schema, nil
+	}
+	if s.schemaErr != nil {
+		return nil, s.schemaErr
+	}
+
+	stored, err := s.cache.Schema(s.settings.ResourceID)
+	if err == nil && time.Since(stored.FetchedAt) < schemaMaxAge {
+		s.schema = stored
+		return stored, nil
+	}
+
+	fields, err := s.fetchFields()
+	if err != nil {
+		if stored != nil {
+			logger.Logger.Warn("Failed to fetch schema, using stored schema", "resource", s.settings.ResourceID, "fetched_at", stored.FetchedAt, "error", err)
+			s.schema = stored
+			return stored, nil
+		}
+		logger.Logger.Warn("Failed to fetch schema", "resource", s.settings.ResourceID, "error", err)
+		s.schemaErr = err
+		return nil, err
+	}
+
+	s.setSchema(fields, stored)
+	return s.schema, nil
+}
+
+// KnownSchema returns the schema without calling the portal: the current
+// schema, the stored one whatever its age, or DefaultSchema when neither
+// exists. It is used for results served from the cache
+func (s *Service) KnownSchema() *models.Schema {
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	if s.schema != nil {
+		return s.schema
+	}
+	if stored, err := s.cache.Schema(s.settings.ResourceID); err == nil {
+		return stored
+	}
+	return s.DefaultSchema()
+}
+
+// DefaultSchema returns the fields the resource is expected to have, from
+// Settings.Columns, for use when the schema cannot be retrieved
+func (s *Service) DefaultSchema() *models.Schema {
+	schema := &models.Schema{ResourceID: s.settings.ResourceID}
+	for _, column := range s.settings.Columns {
+		schema.Fields = append(schema.Fields, models.Field{
+			ID:    column,
+			Type:  "text",
+			Label: config.HeadersMap[column],
+		})
+	}
+	return schema
+}
+
+// ExpectedColumns returns the fields the resource is expected to have
+func (s *Service) ExpectedColumns() []string {
+	return append([]string(nil), s.settings.Columns...)
+}
+
+// fetchFields calls datastore_info for the resource's fields
+func (s *Service) fetchFields() ([]models.Field, error) {
+	logger.Logger.Info("Fetching schema", "resource", s.settings.ResourceID, "endpoint", s.settings.InfoPath)
+	return s.ckan.Info(s.settings.ResourceID)
+}
+
+// updateSchema replaces the schema with the fields from a search response
+func (s *Service) updateSchema(fields []models.Field) {
+	if len(fields) == 0 {
+		return
+	}
+
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	previous := s.schema
+	if previous == nil {
+		previous, _ = s.cache.Schema(s.settings.ResourceID)
+	}
+	s.setSchema(fields, previous)
+}
+
+// setSchema makes fields the current schema and stores it in the cache,
+// logging any fields that were in the previous schema but are now gone.
+// Fields without a label keep the label from the previous schema or get the
+// friendly name from config.HeadersMap. The caller must hold schemaMu
+func (s *Service) setSchema(fields []models.Field, previous *models.Schema) {
+	for i := range fields {
+		if fields[i].Label != "" {
+			continue
+		}
+		if field, ok := previous.Field(fields[i].ID); ok && field.Label != "" {
+			fields[i].Label = field.Label
+		} else {
+			fields[i].Label = config.HeadersMap[fields[i].ID]
+		}
+	}
+
+	if previous != nil && slices.Equal(previous.Fields, fields) && time.Since(previous.FetchedAt) < schemaMaxAge {
+		s.schema = previous
+		s.schemaErr = nil
+		return
+	}
+
+	schema := &models.Schema{
+		ResourceID: s.settings.ResourceID,
+		Fields:     fields,
+		FetchedAt:  time.Now(),
+	}
+
+	if previous != nil {
+		ids := make([]string, len(previous.Fields))
+		for i, field := range previous.Fields {
+			ids[i] = field.ID
+		}
+		if missing := schema.Missing(ids); len(missing) > 0 {
+			logger.Logger.Warn("Fields removed from resource", "resource", s.settings.ResourceID, "fields", missing)
+		}
+	}
+
+	s.schema = schema
+	s.schemaErr = nil
+	if err := s.cache.SetSchema(schema); err != nil {
+		logger.Logger.Warn("Failed to cache schema", "resource", s.settings.ResourceID, "error", err)
+	}
+}
+
+// convertRecords converts the values of the result's records to the types
+// in the schema. Results served from the cache use KnownSchema, so a cache
+// hit makes no request to the portal
+func (s *Service) convertRecords(result *models.SearchResult) {
+	schema := s.KnownSchema()
+	if !result.FromCache {
+		var err error
+		if schema, err = s.Schema(); err != nil {
+			return
+		}
+	}
+	for _, record := range result.Records {
+		schema.Convert(record)
+	}
+}
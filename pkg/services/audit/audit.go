@@ -0,0 +1,287 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/internal/filelock"
+)
+
+// Cache outcomes recorded for a search
+const (
+	CacheHit     = "hit"
+	CacheMiss    = "miss"
+	CacheStale   = "stale"
+	CacheDerived = "derived"
+)
+
+// tailChunk is how much of the end of the log is read at a time to find the
+// last entry
+const tailChunk = 64 * 1024
+
+// Entry is one search recorded in the audit log
+type Entry struct {
+	// Seq numbers entries from 1
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// User is the operating system user that ran the search
+	User string `json:"user"`
+	// Command is the command line of the process that ran the search
+	Command []string `json:"command"`
+	// Query is the normalised search term or SQL statement
+	Query      string            `json:"query"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	Endpoint   string            `json:"endpoint"`
+	ResourceID string            `json:"resource_id"`
+	// Cache is CacheHit, CacheMiss, CacheStale or CacheDerived
+	Cache   string `json:"cache"`
+	Records int    `json:"records"`
+	// Error is set when the search failed
+	Error string `json:"error,omitempty"`
+	// PrevHash is the Hash of the previous entry, or "" for the first one
+	PrevHash string `json:"prev_hash"`
+	// Hash is the SHA-256 of the entry with an empty Hash
+	Hash string `json:"hash"`
+}
+
+// Log appends hash-chained entries to an audit file. Each entry holds the
+// hash of the one before it, so editing, reordering or removing an entry
+// breaks the chain, except removing entries from the end. A Log is safe for
+// concurrent use, including by several processes
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New returns a log that appends to the file at path
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the location of the audit file
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Record appends entry to the log. The time, user and command line are
+// filled in when empty, and the sequence number and hashes are always set
+func (l *Log) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.User == "" {
+		entry.User = currentUser()
+	}
+	if entry.Command == nil {
+		entry.Command = os.Args
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	if err := filelock.Lock(file, true); err != nil {
+		return fmt.Errorf("failed to lock audit log: %v", err)
+	}
+	defer filelock.Unlock(file)
+
+	last, err := lastEntry(file)
+	if err != nil {
+		return err
+	}
+	if last != nil {
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+	} else {
+		entry.Seq = 1
+		entry.PrevHash = ""
+	}
+
+	entry.Hash, err = hashEntry(entry)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %v", err)
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit entry: %v", err)
+	}
+	return file.Sync()
+}
+
+// VerifyError reports where the chain in an audit file is broken
+type VerifyError struct {
+	// Line is the 1-based line of the first bad entry
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit log line %d: %s", e.Line, e.Reason)
+}
+
+// Summary describes the entries of an audit file that were verified
+type Summary struct {
+	// Entries is the number of entries verified
+	Entries int
+	// Head is the hash of the last entry verified. The chain cannot show
+	// that entries were removed from the end of the file, but a head
+	// recorded elsewhere can: it must still be in the chain later on
+	Head string
+}
+
+// Verify checks every entry in the audit file at path: that it parses, that
+// its hash matches its contents, and that its sequence number and previous
+// hash follow on from the entry before it. When expectHead is set, it must
+// be the hash of one of the entries, as it is when the file has only grown
+// since that head was recorded. It returns a summary of the valid entries,
+// and a *VerifyError for the first entry that fails
+func Verify(path, expectHead string) (Summary, error) {
+	var summary Summary
+	file, err := os.Open(path)
+	if err != nil {
+		return summary, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	if err := filelock.Lock(file, false); err != nil {
+		return summary, fmt.Errorf("failed to lock audit log: %v", err)
+	}
+	defer filelock.Unlock(file)
+
+	reader := bufio.NewReader(file)
+	var prev *Entry
+	foundHead := false
+	line := 1
+	for ; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return summary, fmt.Errorf("failed to read audit log: %v", err)
+		}
+		if err == io.EOF {
+			return summary, &VerifyError{line, "entry is not terminated by a newline"}
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return summary, &VerifyError{line, fmt.Sprintf("invalid entry: %v", err)}
+		}
+
+		hash, err := hashEntry(entry)
+		if err != nil {
+			return summary, err
+		}
+		if hash != entry.Hash {
+			return summary, &VerifyError{line, "hash does not match the entry's contents"}
+		}
+
+		switch {
+		case prev == nil && entry.Seq != 1:
+			return summary, &VerifyError{line, fmt.Sprintf("first entry has sequence number %d", entry.Seq)}
+		case prev == nil && entry.PrevHash != "":
+			return summary, &VerifyError{line, "first entry has a previous hash"}
+		case prev != nil && entry.Seq != prev.Seq+1:
+			return summary, &VerifyError{line, fmt.Sprintf("sequence number %d follows %d", entry.Seq, prev.Seq)}
+		case prev != nil && entry.PrevHash != prev.Hash:
+			return summary, &VerifyError{line, "previous hash does not match the entry before it"}
+		}
+
+		prev = &entry
+		summary.Entries++
+		summary.Head = entry.Hash
+		foundHead = foundHead || entry.Hash == expectHead
+	}
+
+	if expectHead != "" && !foundHead {
+		return summary, &VerifyError{line, fmt.Sprintf("expected head %s is not in the log; entries were removed from the end or replaced", expectHead)}
+	}
+	return summary, nil
+}
+
+// hashEntry returns the hex SHA-256 of entry's JSON encoding with an empty
+// Hash
+func hashEntry(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit entry: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastEntry returns the final entry in file, or nil if it is empty. The
+// caller must hold the lock
+func lastEntry(file *os.File) (*Entry, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	// Read backwards from the end until the chunk holds a whole line
+	for chunk := int64(tailChunk); ; chunk *= 2 {
+		offset := size - chunk
+		if offset < 0 {
+			offset = 0
+		}
+		data := make([]byte, size-offset)
+		if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read audit log: %v", err)
+		}
+
+		if data[len(data)-1] != '\n' {
+			return nil, fmt.Errorf("audit log %s ends with an incomplete entry", file.Name())
+		}
+		data = data[:len(data)-1]
+
+		start := bytes.LastIndexByte(data, '\n')
+		if start < 0 && offset > 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(data[start+1:], &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse last audit entry: %v", err)
+		}
+		return &entry, nil
+	}
+}
+
+// currentUser returns the name of the operating system user
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
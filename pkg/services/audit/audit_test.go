@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLog records an entry for each query in a new audit file and returns
+// its path and lines, each with its newline
+func writeLog(t *testing.T, queries ...string) (string, [][]byte) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log := New(path)
+	for _, query := range queries {
+		if err := log.Record(Entry{Query: query, Endpoint: "/api/3/action/datastore_search", Cache: CacheMiss}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, bytes.SplitAfter(data, []byte("\n"))[:len(queries)]
+}
+
+// entryHash returns the hash recorded in an audit file line
+func entryHash(t *testing.T, line []byte) string {
+	t.Helper()
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatal(err)
+	}
+	return entry.Hash
+}
+
+func TestVerify(t *testing.T) {
+	path, lines := writeLog(t, "acme", "widgets", "example")
+	hashes := []string{entryHash(t, lines[0]), entryHash(t, lines[1]), entryHash(t, lines[2])}
+
+	tests := []struct {
+		name string
+		// lines returns the lines to write to the file
+		lines      func() [][]byte
+		expectHead string
+		entries    int
+		// errLine and reason describe the expected *VerifyError, if any
+		errLine int
+		reason  string
+	}{
+		{
+			name:    "intact chain",
+			lines:   func() [][]byte { return lines },
+			entries: 3,
+		},
+		{
+			name: "edited entry",
+			lines: func() [][]byte {
+				edited := bytes.Replace(lines[1], []byte(`"query":"widgets"`), []byte(`"query":"gadgets"`), 1)
+				return [][]byte{lines[0], edited, lines[2]}
+			},
+			entries: 1,
+			errLine: 2,
+			reason:  "hash does not match",
+		},
+		{
+			name:    "reordered entries",
+			lines:   func() [][]byte { return [][]byte{lines[0], lines[2], lines[1]} },
+			entries: 1,
+			errLine: 2,
+			reason:  "sequence number 3 follows 1",
+		},
+		{
+			name:    "entry removed from the start",
+			lines:   func() [][]byte { return lines[1:] },
+			errLine: 1,
+			reason:  "first entry has sequence number 2",
+		},
+		{
+			name:    "truncated log",
+			lines:   func() [][]byte { return lines[:2] },
+			entries: 2,
+		},
+		{
+			name:       "truncated log with expected head",
+			lines:      func() [][]byte { return lines[:2] },
+			expectHead: hashes[2],
+			entries:    2,
+			errLine:    3,
+			reason:     "expected head",
+		},
+		{
+			name: "incomplete last line",
+			lines: func() [][]byte {
+				return [][]byte{lines[0], lines[1], lines[2][:len(lines[2])/2]}
+			},
+			entries: 2,
+			errLine: 3,
+			reason:  "not terminated by a newline",
+		},
+		{
+			name:       "expected head matches an earlier entry",
+			lines:      func() [][]byte { return lines },
+			expectHead: hashes[1],
+			entries:    3,
+		},
+		{
+			name:       "expected head matches the last entry",
+			lines:      func() [][]byte { return lines },
+			expectHead: hashes[2],
+			entries:    3,
+		},
+		{
+			name:       "expected head does not match",
+			lines:      func() [][]byte { return lines },
+			expectHead: strings.Repeat("0", 64),
+			entries:    3,
+			errLine:    4,
+			reason:     "expected head",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, bytes.Join(tt.lines(), nil), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			summary, err := Verify(path, tt.expectHead)
+			if summary.Entries != tt.entries {
+				t.Errorf("verified %d entries, want %d", summary.Entries, tt.entries)
+			}
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Verify failed: %v", err)
+				}
+				if summary.Head != hashes[tt.entries-1] {
+					t.Errorf("head is %s, want %s", summary.Head, hashes[tt.entries-1])
+				}
+				return
+			}
+
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("got error %v, want a *VerifyError", err)
+			}
+			if verifyErr.Line != tt.errLine || !strings.Contains(verifyErr.Reason, tt.reason) {
+				t.Errorf("got %v, want line %d: %s", verifyErr, tt.errLine, tt.reason)
+			}
+		})
+	}
+}
+
+func TestRecordContinuesChain(t *testing.T) {
+	// The long query makes the last entry bigger than the chunk lastEntry
+	// reads first
+	path, lines := writeLog(t, "acme", strings.Repeat("x", 2*tailChunk))
+
+	log := New(path)
+	if err := log.Record(Entry{Query: "widgets"}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	last, err := lastEntry(file)
+	if err != nil {
+		t.Fatalf("lastEntry failed: %v", err)
+	}
+	if last.Seq != 3 || last.Query != "widgets" || last.PrevHash != entryHash(t, lines[1]) {
+		t.Errorf("last entry is %d %q after %s, want 3 \"widgets\" after the second entry", last.Seq, last.Query, last.PrevHash)
+	}
+
+	summary, err := Verify(path, entryHash(t, lines[1]))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if summary.Entries != 3 || summary.Head != last.Hash {
+		t.Errorf("verified %d entries up to %s, want 3 up to %s", summary.Entries, summary.Head, last.Hash)
+	}
+}
+
+func TestRecordRefusesIncompleteLastLine(t *testing.T) {
+	path, lines := writeLog(t, "acme", "widgets")
+	data := append(append([]byte(nil), lines[0]...), lines[1][:len(lines[1])-1]...)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New(path).Record(Entry{Query: "example"}); err == nil || !strings.Contains(err.Error(), "incomplete entry") {
+		t.Errorf("Record on a log with an incomplete last line returned %v, want an incomplete entry error", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, data) {
+		t.Error("Record changed a log with an incomplete last line")
+	}
+}
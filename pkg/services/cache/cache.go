@@ -1,134 +1,554 @@
 package cache
 
 import (
-	"encoding/json"
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
 	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
 )
 
-// initCacheDir creates the cache directory if it doesn't exist
-func initCacheDir() error {
-	if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
+// Cache stores API responses as files in a directory. A Cache is safe for
+// concurrent use, and several processes may share the same directory
+type Cache struct {
+	dir         string
+	expiration  time.Duration
+	staleGrace  time.Duration
+	compression string
+}
+
+// New creates a cache using the directory, expiration, stale grace period
+// and compression from settings
+func New(settings config.Settings) *Cache {
+	return &Cache{
+		dir:         settings.CacheDir,
+		expiration:  settings.CacheExpiration,
+		staleGrace:  settings.CacheStaleGrace,
+		compression: settings.CacheCompression,
+	}
+}
+
+// Dir returns the directory the cache is stored in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// initDir creates the cache directory if it doesn't exist
+func (c *Cache) initDir() error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
 		return fmt.Errorf("failed to create cache directory: %v", err)
 	}
 	return nil
 }
 
-// GetCache retrieves cached data for a given query and filters
-func GetCache(query string, filters map[string]string) (interface{}, error) {
-	if err := initCacheDir(); err != nil {
+// Get retrieves cached data for a given query and filters
+func (c *Cache) Get(query string, filters map[string]string) (interface{}, error) {
+	entry, stale, err := c.Lookup(query, filters)
+	if err != nil {
 		return nil, err
 	}
 
+	if stale {
+		return nil, fmt.Errorf("cache expired")
+	}
+
+	return entry.Data, nil
+}
+
+// Lookup retrieves the cache entry for a given query and filters. Entries
+// that have expired but are still within the stale grace period are returned
+// with stale set to true; entries past the grace window are removed
+func (c *Cache) Lookup(query string, filters map[string]string) (entry *models.CacheEntry, stale bool, err error) {
+	if err := c.initDir(); err != nil {
+		return nil, false, err
+	}
+
 	cacheKey := generateCacheKey(query, filters)
-	cacheFile := filepath.Join(config.CacheDir, cacheKey+".json")
+	cacheFile := filepath.Join(c.dir, cacheKey+".json")
 
-	data, err := os.ReadFile(cacheFile)
+	entry, data, err := c.readEntry(cacheFile)
 	if err != nil {
+		return nil, false, err
+	}
+	// Keys are shortened past maxKeyLength, so check the entry is for this search
+	if entry.Query != query || !maps.Equal(entry.Filters, filters) {
+		logger.Logger.Warn("Cache entry is for a different search", "key", cacheKey, "query", query, "filters", filters, "cached_query", entry.Query, "cached_filters", entry.Filters)
+		return nil, false, fmt.Errorf("cache entry %s is for a different search", cacheKey)
+	}
+
+	now := time.Now()
+	if now.After(entry.Expiration.Add(c.staleGrace)) {
+		logger.Logger.Debug("Removing cache entry past its stale grace period", "key", cacheKey, "query", query, "filters", filters, "expired", entry.Expiration)
+		c.removeIfUnchanged(cacheFile, data)
+		return nil, false, fmt.Errorf("cache expired")
+	}
+
+	return entry, now.After(entry.Expiration), nil
+}
+
+// Set stores data in the cache with expiration
+func (c *Cache) Set(query string, filters map[string]string, data interface{}) error {
+	return c.set(query, filters, data, false)
+}
+
+// SetComplete stores data that holds every record matching the query
+// and filters, so FindCovering can answer narrower searches from it
+func (c *Cache) SetComplete(query string, filters map[string]string, data interface{}) error {
+	return c.set(query, filters, data, true)
+}
+
+// FindCovering looks for an unexpired complete cache entry for the same
+// query with fewer filters or wider date ranges than filters, as reported by
+// coversFilters. Every record matching the narrower search is contained in
+// such an entry
+func (c *Cache) FindCovering(query string, filters map[string]string) (*models.CacheEntry, error) {
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("no covering cache entry")
+	}
+
+	if err := c.initDir(); err != nil {
 		return nil, err
 	}
 
-	var entry models.CacheEntry
-	if err := json.Unmarshal(data, &entry); err != nil {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
 		return nil, err
 	}
 
-	if time.Now().After(entry.Expiration) {
-		os.Remove(cacheFile)
-		return nil, fmt.Errorf("cache expired")
+	// Keys start with the query, so only those files need to be read
+	prefix := keyPrefix(query)
+	var best *models.CacheEntry
+	for _, file := range files {
+		name := file.Name()
+		if filepath.Ext(name) != ".json" || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		entry, _, err := c.readEntry(filepath.Join(c.dir, name))
+		if err != nil || !entry.Complete || entry.Query != query || time.Now().After(entry.Expiration) {
+			continue
+		}
+		if !coversFilters(entry.Filters, filters) {
+			continue
+		}
+
+		// Prefer the narrowest covering entry, as it has the fewest records to filter
+		if best == nil || len(entry.Filters) > len(best.Filters) {
+			best = entry
+		}
 	}
 
-	return entry.Data, nil
+	if best == nil {
+		return nil, fmt.Errorf("no covering cache entry")
+	}
+	return best, nil
 }
 
-// SetCache stores data in the cache with expiration
-func SetCache(query string, filters map[string]string, data interface{}) error {
-	if err := initCacheDir(); err != nil {
+// coversFilters reports whether a result cached with the broader filters
+// contains every record matching the narrower ones, for a search that is
+// not the same. Every broader filter must also be in narrower, with the same
+// value or, for a date range, with a range inside the cached one
+func coversFilters(broader, narrower map[string]string) bool {
+	if maps.Equal(broader, narrower) {
+		return false
+	}
+	for k, v := range broader {
+		n, ok := narrower[k]
+		if !ok {
+			return false
+		}
+		if n == v {
+			continue
+		}
+		cached, ok := models.ParseDateRange(v)
+		if !ok {
+			return false
+		}
+		wanted, ok := models.ParseDateRange(n)
+		if !ok || !cached.Covers(wanted) {
+			return false
+		}
+	}
+	return true
+}
+
+// set stores data in the cache with expiration
+func (c *Cache) set(query string, filters map[string]string, data interface{}, complete bool) error {
+	if err := c.initDir(); err != nil {
 		return err
 	}
 
 	cacheKey := generateCacheKey(query, filters)
-	cacheFile := filepath.Join(config.CacheDir, cacheKey+".json")
+	cacheFile := filepath.Join(c.dir, cacheKey+".json")
 
 	entry := models.CacheEntry{
+		Query:      query,
+		Filters:    filters,
+		Complete:   complete,
 		Data:       data,
 		Timestamp:  time.Now(),
-		Expiration: time.Now().Add(config.CacheExpiration),
+		Expiration: time.Now().Add(c.expiration),
 	}
 
-	jsonData, err := json.Marshal(entry)
+	encoded, err := c.encodeEntry(cacheKey, entry)
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(cacheFile, jsonData, 0644)
+	err = c.withLock(true, func() error {
+		return writeFileAtomic(cacheFile, encoded)
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Logger.Debug("Wrote cache entry", "key", cacheKey, "query", query, "filters", filters, "complete", complete, "bytes", len(encoded), "expires", entry.Expiration)
+	return nil
 }
 
-// RemoveExpiredCache removes all expired cache entries
-func RemoveExpiredCache() error {
-	if err := initCacheDir(); err != nil {
+// RemoveExpired removes cache entries past their stale grace period,
+// quarantines corrupted ones and deletes temporary files left behind by
+// interrupted writes. Expired entries still within the grace period are
+// kept, as they are served when the API fails
+func (c *Cache) RemoveExpired() error {
+	if err := c.initDir(); err != nil {
 		return err
 	}
 
-	files, err := os.ReadDir(config.CacheDir)
-	if err != nil {
+	return c.withLock(true, func() error {
+		files, err := os.ReadDir(c.dir)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			cacheFile := filepath.Join(c.dir, file.Name())
+
+			if filepath.Ext(file.Name()) == ".tmp" {
+				if info, err := file.Info(); err == nil && time.Since(info.ModTime()) > tempFileMaxAge {
+					os.Remove(cacheFile)
+				}
+				continue
+			}
+
+			if filepath.Ext(file.Name()) != ".json" {
+				continue
+			}
+
+			data, err := os.ReadFile(cacheFile)
+			if err != nil {
+				continue
+			}
+
+			entry, err := decodeEntry(data)
+			if err != nil {
+				c.quarantine(cacheFile, err)
+				continue
+			}
+
+			if time.Now().After(entry.Expiration.Add(c.staleGrace)) {
+				os.Remove(cacheFile)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Clean removes all cache entries
+func (c *Cache) Clean() error {
+	if err := c.initDir(); err != nil {
 		return err
 	}
 
+	return c.withLock(true, func() error {
+		files, err := os.ReadDir(c.dir)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if filepath.Ext(file.Name()) == ".json" {
+				os.Remove(filepath.Join(c.dir, file.Name()))
+			}
+		}
+
+		return nil
+	})
+}
+
+// List returns information about every cache entry, newest first
+func (c *Cache) List() ([]models.CacheInfo, error) {
+	if err := c.initDir(); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []models.CacheInfo
 	for _, file := range files {
 		if filepath.Ext(file.Name()) != ".json" {
 			continue
 		}
 
-		cacheFile := filepath.Join(config.CacheDir, file.Name())
-		data, err := os.ReadFile(cacheFile)
+		key := strings.TrimSuffix(file.Name(), ".json")
+		entry, err := c.Entry(key)
 		if err != nil {
 			continue
 		}
 
-		var entry models.CacheEntry
-		if err := json.Unmarshal(data, &entry); err != nil {
+		fileInfo, err := file.Info()
+		if err != nil {
 			continue
 		}
 
-		if time.Now().After(entry.Expiration) {
-			os.Remove(cacheFile)
+		info := models.CacheInfo{
+			Key:        key,
+			Query:      entry.Query,
+			Filters:    entry.Filters,
+			Timestamp:  entry.Timestamp,
+			Expiration: entry.Expiration,
+			Size:       fileInfo.Size(),
 		}
+		if records, ok := entry.Data.([]interface{}); ok {
+			info.Records = len(records)
+		}
+		infos = append(infos, info)
 	}
 
-	return nil
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Timestamp.After(infos[j].Timestamp)
+	})
+
+	return infos, nil
 }
 
-// CleanCache removes all cache entries
-func CleanCache() error {
-	if err := initCacheDir(); err != nil {
-		return err
+// Entry reads a cache entry by key regardless of its expiration
+func (c *Cache) Entry(key string) (*models.CacheEntry, error) {
+	if key == "" || filepath.Base(key) != key {
+		return nil, fmt.Errorf("invalid cache key: %q", key)
+	}
+
+	entry, _, err := c.readEntry(filepath.Join(c.dir, key+".json"))
+	return entry, err
+}
+
+// Purge removes cache entries whose key or query matches the given
+// shell pattern and returns the number of entries removed
+func (c *Cache) Purge(pattern string) (int, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return 0, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+	}
+
+	infos, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	err = c.withLock(true, func() error {
+		for _, info := range infos {
+			keyMatch, _ := filepath.Match(pattern, info.Key)
+			queryMatch, _ := filepath.Match(pattern, info.Query)
+			if !keyMatch && !queryMatch {
+				continue
+			}
+			if err := os.Remove(filepath.Join(c.dir, info.Key+".json")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+
+	return removed, err
+}
+
+// Export writes every cache entry to w as a gzipped tar archive and
+// returns the number of entries written. Stored schemas are included, so
+// searches answered from the imported entries need not fetch them
+func (c *Cache) Export(w io.Writer) (int, error) {
+	if err := c.initDir(); err != nil {
+		return 0, err
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	count := 0
+	err := c.withLock(false, func() error {
+		files, err := os.ReadDir(c.dir)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if filepath.Ext(file.Name()) != ".json" {
+				continue
+			}
+			if err := addToArchive(tarWriter, file.Name(), filepath.Join(c.dir, file.Name())); err != nil {
+				return err
+			}
+			count++
+		}
+
+		schemas, err := os.ReadDir(filepath.Join(c.dir, schemaDirName))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for _, file := range schemas {
+			if filepath.Ext(file.Name()) != ".json" {
+				continue
+			}
+			name := path.Join(schemaDirName, file.Name())
+			if err := addToArchive(tarWriter, name, filepath.Join(c.dir, schemaDirName, file.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return count, err
 	}
+	return count, gzipWriter.Close()
+}
 
-	files, err := os.ReadDir(config.CacheDir)
+// addToArchive writes the file at filePath to tarWriter as name
+func addToArchive(tarWriter *tar.Writer, name, filePath string) error {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		if filepath.Ext(file.Name()) == ".json" {
-			os.Remove(filepath.Join(config.CacheDir, file.Name()))
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tarWriter.Write(data)
+	return err
+}
+
+// Import reads a gzipped tar archive produced by Export and
+// stores its entries and schemas in the cache directory. Existing entries
+// with the same key are replaced. It returns the number of entries imported
+func (c *Cache) Import(r io.Reader) (int, error) {
+	if err := c.initDir(); err != nil {
+		return 0, err
+	}
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cache archive: %v", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	count := 0
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read cache archive: %v", err)
+		}
+
+		// Only entries at the top level and schemas in their directory are
+		// imported, so an archive cannot write elsewhere
+		dir, name := path.Split(header.Name)
+		if header.Typeflag != tar.TypeReg || (dir != "" && dir != schemaDirName+"/") || filepath.Base(name) != name || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return count, err
+		}
+
+		if dir != "" {
+			if err := c.importSchema(name, data); err != nil {
+				return count, err
+			}
+			continue
+		}
+
+		if _, err := decodeEntry(data); err != nil {
+			return count, fmt.Errorf("invalid cache entry %s: %v", name, err)
+		}
+
+		err = c.withLock(true, func() error {
+			return writeFileAtomic(filepath.Join(c.dir, name), data)
+		})
+		if err != nil {
+			return count, err
 		}
+		count++
 	}
 
-	return nil
+	return count, nil
 }
 
-// generateCacheKey creates a unique key for cache entries
+// maxKeyLength keeps cache file names within filesystem limits. Longer keys
+// are shortened and suffixed with a hash of the full key
+const maxKeyLength = 150
+
+// generateCacheKey creates a unique key for cache entries. Filters are
+// sorted so the same search always maps to the same key, and the key is
+// escaped so it is a valid file name on every platform. Filters are joined
+// with & and =, which QueryEscape always escapes, so no query or filter
+// value can produce the key of another search
 func generateCacheKey(query string, filters map[string]string) string {
-	key := query
-	for k, v := range filters {
-		key += fmt.Sprintf("_%s_%s", k, v)
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	key := url.QueryEscape(query)
+	for _, k := range keys {
+		key += fmt.Sprintf("&%s=%s", url.QueryEscape(k), url.QueryEscape(filters[k]))
+	}
+
+	if len(key) > maxKeyLength {
+		sum := sha256.Sum256([]byte(key))
+		key = key[:maxKeyLength] + "-" + hex.EncodeToString(sum[:8])
 	}
 	return key
 }
+
+// keyPrefix returns the prefix shared by the keys of every search for query
+func keyPrefix(query string) string {
+	prefix := url.QueryEscape(query)
+	if len(prefix) > maxKeyLength {
+		prefix = prefix[:maxKeyLength]
+	}
+	return prefix
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// testCache returns a cache in a temporary directory
+func testCache(t *testing.T) *Cache {
+	settings := config.Default()
+	settings.CacheDir = t.TempDir()
+	return New(settings)
+}
+
+func TestGenerateCacheKeyIsUnique(t *testing.T) {
+	type search struct {
+		query   string
+		filters map[string]string
+	}
+	tests := []struct {
+		name string
+		a, b search
+	}{
+		{
+			"filter in query",
+			search{"acme_BN_STATUS_Registered", nil},
+			search{"acme", map[string]string{"BN_STATUS": "Registered"}},
+		},
+		{
+			"separators in query",
+			search{"acme&BN_STATUS=Registered", nil},
+			search{"acme", map[string]string{"BN_STATUS": "Registered"}},
+		},
+		{
+			"separators in filter value",
+			search{"acme", map[string]string{"BN_STATE_OF_REG": "NSW&BN_STATUS=Registered"}},
+			search{"acme", map[string]string{"BN_STATE_OF_REG": "NSW", "BN_STATUS": "Registered"}},
+		},
+		{
+			"separators in filter name",
+			search{"acme", map[string]string{"BN_STATUS=Registered&BN_STATE_OF_REG": "NSW"}},
+			search{"acme", map[string]string{"BN_STATUS": "Registered&BN_STATE_OF_REG=NSW"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := generateCacheKey(tt.a.query, tt.a.filters)
+			b := generateCacheKey(tt.b.query, tt.b.filters)
+			if a == b {
+				t.Errorf("%q %v and %q %v share the key %s", tt.a.query, tt.a.filters, tt.b.query, tt.b.filters, a)
+			}
+		})
+	}
+}
+
+func TestLookupRejectsEntryForAnotherSearch(t *testing.T) {
+	c := testCache(t)
+	filters := map[string]string{"BN_STATUS": "Registered"}
+	if err := c.Set("acme", filters, []map[string]interface{}{{"BN_NAME": "ACME"}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, _, err := c.Lookup("acme", filters); err != nil {
+		t.Fatalf("Lookup of the stored search failed: %v", err)
+	}
+
+	// Put the entry where another search would look for it
+	data, err := os.ReadFile(filepath.Join(c.Dir(), generateCacheKey("acme", filters)+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(c.Dir(), generateCacheKey("acme pty", filters)+".json")
+	if err := os.WriteFile(other, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, _, err := c.Lookup("acme pty", filters); err == nil {
+		t.Errorf("Lookup returned the entry for %q %v", entry.Query, entry.Filters)
+	}
+}
+
+func TestExportImportIncludesSchemas(t *testing.T) {
+	source := testCache(t)
+	if err := source.Set("acme", nil, []map[string]interface{}{{"BN_NAME": "ACME"}}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	schema := &models.Schema{
+		ResourceID: "resource",
+		Fields:     []models.Field{{ID: "BN_NAME", Type: "text"}},
+		FetchedAt:  time.Now(),
+	}
+	if err := source.SetSchema(schema); err != nil {
+		t.Fatalf("SetSchema failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if n, err := source.Export(&archive); err != nil || n != 1 {
+		t.Fatalf("Export wrote %d entries, %v; want 1", n, err)
+	}
+
+	target := testCache(t)
+	if n, err := target.Import(&archive); err != nil || n != 1 {
+		t.Fatalf("Import read %d entries, %v; want 1", n, err)
+	}
+	if _, _, err := target.Lookup("acme", nil); err != nil {
+		t.Errorf("imported entry not found: %v", err)
+	}
+	imported, err := target.Schema("resource")
+	if err != nil {
+		t.Fatalf("imported schema not found: %v", err)
+	}
+	if !slices.Equal(imported.Fields, schema.Fields) {
+		t.Errorf("imported schema has fields %v, want %v", imported.Fields, schema.Fields)
+	}
+}
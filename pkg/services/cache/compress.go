@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// Supported values for Settings.CacheCompression
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// gzipMagic is the header every gzip stream starts with
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// encodeEntry serialises a cache entry, compressing it according to
+// the cache's compression setting
+func (c *Cache) encodeEntry(key string, entry models.CacheEntry) ([]byte, error) {
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.compression {
+	case CompressionNone, "":
+		return jsonData, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buf)
+		if _, err := gzipWriter.Write(jsonData); err != nil {
+			return nil, err
+		}
+		if err := gzipWriter.Close(); err != nil {
+			return nil, err
+		}
+
+		logger.Logger.Debug("Compressed cache entry", "key", key, "compression", CompressionGzip,
+			"bytes", len(jsonData), "compressed_bytes", buf.Len(), "ratio", float64(len(jsonData))/float64(buf.Len()))
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported cache compression: %s", c.compression)
+	}
+}
+
+// decodeEntry parses a cache entry written by encodeEntry. The compression
+// is detected from the data, so entries written with any setting, including
+// older uncompressed ones, can be read
+func decodeEntry(data []byte) (*models.CacheEntry, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer gzipReader.Close()
+
+		data, err = io.ReadAll(gzipReader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var entry models.CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// schemaDirName holds the datastore schemas, one file per resource
+const schemaDirName = "schema"
+
+// Schema returns the stored schema of a datastore resource
+func (c *Cache) Schema(resourceID string) (*models.Schema, error) {
+	var data []byte
+	err := c.withLock(false, func() error {
+		var err error
+		data, err = os.ReadFile(c.schemaPath(resourceID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var schema models.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse cached schema: %v", err)
+	}
+	return &schema, nil
+}
+
+// SetSchema stores the schema of a datastore resource
+func (c *Cache) SetSchema(schema *models.Schema) error {
+	if err := os.MkdirAll(filepath.Join(c.dir, schemaDirName), 0755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %v", err)
+	}
+
+	return c.withLock(true, func() error {
+		return writeFileAtomic(c.schemaPath(schema.ResourceID), data)
+	})
+}
+
+// importSchema stores a schema file read from an archive made by Export
+func (c *Cache) importSchema(name string, data []byte) error {
+	var schema models.Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid schema %s: %v", name, err)
+	}
+	if err := os.MkdirAll(filepath.Join(c.dir, schemaDirName), 0755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %v", err)
+	}
+
+	return c.withLock(true, func() error {
+		return writeFileAtomic(filepath.Join(c.dir, schemaDirName, name), data)
+	})
+}
+
+func (c *Cache) schemaPath(resourceID string) string {
+	return filepath.Join(c.dir, schemaDirName, generateCacheKey(resourceID, nil)+".json")
+}
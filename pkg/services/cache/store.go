@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/internal/filelock"
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+const (
+	// lockFileName is the advisory lock shared by every process using the
+	// cache directory
+	lockFileName = ".lock"
+	// quarantineDirName holds cache entries that could not be parsed
+	quarantineDirName = "quarantine"
+	// tempFileMaxAge is how old a leftover temporary file must be before
+	// RemoveExpired deletes it
+	tempFileMaxAge = time.Hour
+)
+
+// withLock runs fn while holding the cache directory lock. Readers take a
+// shared lock and writers an exclusive one, so processes sharing the cache
+// never see each other's partial updates
+func (c *Cache) withLock(exclusive bool, fn func() error) error {
+	file, err := os.OpenFile(filepath.Join(c.dir, lockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open cache lock: %v", err)
+	}
+	defer file.Close()
+
+	if err := filelock.Lock(file, exclusive); err != nil {
+		return fmt.Errorf("failed to lock cache: %v", err)
+	}
+	defer filelock.Unlock(file)
+
+	return fn()
+}
+
+// writeFileAtomic writes data to a temporary file in the cache directory and
+// renames it over path, so a crash never leaves a truncated entry behind. The
+// caller must hold the exclusive lock
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// readEntry reads and parses the cache entry at path under a shared lock.
+// Entries that cannot be parsed are moved to the quarantine directory
+func (c *Cache) readEntry(path string) (*models.CacheEntry, []byte, error) {
+	var data []byte
+	err := c.withLock(false, func() error {
+		var err error
+		data, err = os.ReadFile(path)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := decodeEntry(data)
+	if err != nil {
+		parseErr := err
+		c.withLock(true, func() error {
+			if unchanged(path, data) {
+				c.quarantine(path, parseErr)
+			}
+			return nil
+		})
+		return nil, nil, fmt.Errorf("corrupted cache entry %s: %v", filepath.Base(path), parseErr)
+	}
+
+	return entry, data, nil
+}
+
+// removeIfUnchanged deletes path unless another process has replaced it
+// since data was read
+func (c *Cache) removeIfUnchanged(path string, data []byte) error {
+	return c.withLock(true, func() error {
+		if !unchanged(path, data) {
+			return nil
+		}
+		return os.Remove(path)
+	})
+}
+
+// unchanged reports whether the file at path still holds data. The caller
+// must hold the lock
+func unchanged(path string, data []byte) bool {
+	current, err := os.ReadFile(path)
+	return err == nil && bytes.Equal(current, data)
+}
+
+// quarantine moves a corrupted cache entry out of the way so it is not read
+// again. The caller must hold the exclusive lock
+func (c *Cache) quarantine(path string, reason error) {
+	dir := filepath.Join(c.dir, quarantineDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Logger.Error("Failed to create cache quarantine directory", "dir", dir, "error", err)
+		os.Remove(path)
+		return
+	}
+
+	name := fmt.Sprintf("%s.%s.json", strings.TrimSuffix(filepath.Base(path), ".json"), time.Now().Format("20060102150405"))
+	if err := os.Rename(path, filepath.Join(dir, name)); err != nil {
+		logger.Logger.Error("Failed to quarantine corrupted cache entry", "path", path, "error", err)
+		os.Remove(path)
+		return
+	}
+
+	logger.Logger.Warn("Quarantined corrupted cache entry", "key", strings.TrimSuffix(filepath.Base(path), ".json"), "quarantined_as", name, "error", reason)
+}
@@ -2,7 +2,10 @@ package charts
 
 import (
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
 )
 
 func GetAverageAgeOfBusinesses(data []map[string]interface{}) (float64, error) {
@@ -93,3 +96,38 @@ func GetRegistrationStateChart(data []map[string]interface{}) {
 		fmt.Printf("%s: %d\n", state, count)
 	}
 }
+
+// GetBusinessStats summarises the records behind the charts: the number of
+// records and of registered businesses, the average age, and the counts by
+// status, state and year of registration
+func GetBusinessStats(data []map[string]interface{}) models.BusinessStats {
+	stats := models.BusinessStats{
+		TotalCount:         len(data),
+		StatusDistribution: make(map[string]int),
+		StateDistribution:  make(map[string]int),
+		RegistrationByYear: make(map[string]int),
+	}
+
+	for _, record := range data {
+		if status, ok := record["BN_STATUS"].(string); ok {
+			stats.StatusDistribution[status]++
+			if status == "Registered" {
+				stats.ActiveCount++
+			}
+		}
+		if state, ok := record["BN_STATE_OF_REG"].(string); ok {
+			stats.StateDistribution[state]++
+		}
+		if regDate, ok := record["BN_REG_DT"].(string); ok {
+			if parsedDate, err := time.Parse("02/01/2006", regDate); err == nil {
+				stats.RegistrationByYear[strconv.Itoa(parsedDate.Year())]++
+			}
+		}
+	}
+
+	if averageAge, err := GetAverageAgeOfBusinesses(data); err == nil {
+		stats.AverageAge = averageAge
+	}
+
+	return stats
+}
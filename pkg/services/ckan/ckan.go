@@ -0,0 +1,194 @@
+package ckan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+)
+
+// Endpoints are the paths of the CKAN actions the client calls, relative to
+// the portal URL
+type Endpoints struct {
+	Search       string
+	SearchSQL    string
+	Info         string
+	PackageShow  string
+	ResourceShow string
+}
+
+// DefaultEndpoints returns the standard action paths under actionPath, for
+// example "/api/3/action" or data.gov.au's "/data/api/action"
+func DefaultEndpoints(actionPath string) Endpoints {
+	return Endpoints{
+		Search:       path.Join(actionPath, "datastore_search"),
+		SearchSQL:    path.Join(actionPath, "datastore_search_sql"),
+		Info:         path.Join(actionPath, "datastore_info"),
+		PackageShow:  path.Join(actionPath, "package_show"),
+		ResourceShow: path.Join(actionPath, "resource_show"),
+	}
+}
+
+// Client calls the action API of a CKAN portal. A Client is safe for
+// concurrent use
+type Client struct {
+	host       string
+	token      string
+	endpoints  Endpoints
+	httpClient *http.Client
+
+	bodyLogging  BodyLogging
+	fieldPattern *regexp.Regexp
+	paramPattern *regexp.Regexp
+	redactors    []Redactor
+}
+
+// DefaultBodyLogLimit is the number of bytes of each body logged until
+// SetBodyLogging is called
+const DefaultBodyLogLimit = 4096
+
+// NewClient creates a client for the portal at host. A non-empty token is
+// sent in the Authorization header of every request. A nil httpClient uses
+// one with a 10 second timeout. Bodies are logged at debug level, truncated
+// to DefaultBodyLogLimit bytes, until SetBodyLogging is called
+func NewClient(host, token string, endpoints Endpoints, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: 10 * time.Second,
+		}
+	}
+	if token != "" && strings.HasPrefix(host, "http://") {
+		logger.Logger.Warn("Sending API token over unencrypted HTTP", "host", redactURL(host))
+	}
+	return &Client{
+		host:         host,
+		token:        token,
+		endpoints:    endpoints,
+		httpClient:   httpClient,
+		bodyLogging:  BodyLogging{Mode: BodyLogTruncate, Limit: DefaultBodyLogLimit},
+		fieldPattern: fieldPattern(DefaultRedactFields),
+		paramPattern: paramPattern(DefaultRedactFields),
+	}
+}
+
+// Host returns the portal URL
+func (c *Client) Host() string {
+	return c.host
+}
+
+// response is the envelope CKAN wraps every action result in
+type response struct {
+	Help    string          `json:"help"`
+	Success bool            `json:"success"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call posts a JSON request body to an action endpoint and decodes the
+// action's result into out
+func (c *Client) call(endpoint string, requestBody map[string]interface{}, out interface{}) error {
+	url := fmt.Sprintf("%s%s", c.host, endpoint)
+
+	// Convert request body to JSON
+	jsonBody, err := json.Marshal(requestBody)
+	if err != nil {
+		logger.Logger.Error("Failed to marshal request body", "endpoint", endpoint, "error", err)
+		return fmt.Errorf("failed to marshal request body: %v", err)
+	}
+
+	// Create request
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		logger.Logger.Error("Failed to create request", "endpoint", endpoint, "error", err)
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+
+	// Set headers
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+
+	// Make request
+	logger.Logger.Debug("Making POST request", "url", c.redact(redactURL(url)), "endpoint", endpoint)
+	c.logBody("Request body", endpoint, jsonBody)
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		err = errors.New(c.redact(err.Error()))
+		logger.Logger.Error("Request failed", "endpoint", endpoint, "duration", time.Since(start), "error", err)
+		return fmt.Errorf("failed to make request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Logger.Error("Failed to read response", "endpoint", endpoint, "error", err)
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+
+	logger.Logger.Info("Request completed", "endpoint", endpoint, "status", resp.StatusCode, "bytes", len(body), "duration", time.Since(start))
+
+	c.logBody("Response body", endpoint, body)
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		logger.Logger.Error("Request was not authorised", "endpoint", endpoint, "status", resp.StatusCode, "token", c.token != "")
+		if c.token == "" {
+			return fmt.Errorf("%s: the portal requires an API token", resp.Status)
+		}
+		return fmt.Errorf("%s: the API token was rejected", resp.Status)
+	}
+
+	// CKAN describes failed actions in a JSON body, but proxies and servers
+	// in front of it may answer with anything, so report the status first
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp response
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != nil {
+			logger.Logger.Error("Action failed", "endpoint", endpoint, "status", resp.StatusCode, "type", errResp.Error.Type, "message", errResp.Error.Message)
+			if errResp.Error.Message == "" {
+				return fmt.Errorf("%s: %s", resp.Status, errResp.Error.Type)
+			}
+			return fmt.Errorf("%s: %s: %s", resp.Status, errResp.Error.Type, errResp.Error.Message)
+		}
+		logger.Logger.Error("Request returned an error status", "endpoint", endpoint, "status", resp.StatusCode)
+		return fmt.Errorf("portal returned %s", resp.Status)
+	}
+
+	// Parse response
+	var actionResp response
+	if err := json.Unmarshal(body, &actionResp); err != nil {
+		logger.Logger.Error("Failed to parse response", "endpoint", endpoint, "error", err)
+		return fmt.Errorf("failed to parse response: %v", err)
+	}
+
+	if actionResp.Error != nil {
+		logger.Logger.Error("Action failed", "endpoint", endpoint, "type", actionResp.Error.Type, "message", actionResp.Error.Message)
+		if actionResp.Error.Message == "" {
+			return fmt.Errorf("%s", actionResp.Error.Type)
+		}
+		return fmt.Errorf("%s: %s", actionResp.Error.Type, actionResp.Error.Message)
+	}
+	if len(actionResp.Result) == 0 || string(actionResp.Result) == "null" {
+		logger.Logger.Error("Invalid response format: result not found", "endpoint", endpoint)
+		return fmt.Errorf("invalid response format: result not found")
+	}
+
+	if err := json.Unmarshal(actionResp.Result, out); err != nil {
+		logger.Logger.Error("Failed to parse result", "endpoint", endpoint, "error", err)
+		return fmt.Errorf("failed to parse result: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package ckan
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// SearchRequest is a datastore_search query against one resource
+type SearchRequest struct {
+	ResourceID string
+	// Query is a full text search across the resource
+	Query   string
+	Filters map[string]string
+	Limit   int
+	Offset  int
+	// Sort orders the records, for example "BN_NAME asc"
+	Sort string
+}
+
+// SearchResult holds the records returned by a datastore search
+type SearchResult struct {
+	Records []map[string]interface{}
+	// Total is the number of matching records, or -1 when the portal does
+	// not report it
+	Total int
+	// Fields describes the columns of the records
+	Fields []models.Field
+}
+
+// rawSearchResult is the result of datastore_search and
+// datastore_search_sql as CKAN returns it
+type rawSearchResult struct {
+	Records []map[string]interface{} `json:"records"`
+	Total   *int                     `json:"total"`
+	Fields  []rawField               `json:"fields"`
+}
+
+// rawField is a field as CKAN describes it, with its label under info
+type rawField struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Info struct {
+		Label string `json:"label"`
+	} `json:"info"`
+}
+
+// Search calls datastore_search
+func (c *Client) Search(req SearchRequest) (*SearchResult, error) {
+	requestBody := map[string]interface{}{
+		"resource_id": req.ResourceID,
+		"limit":       req.Limit,
+		"offset":      req.Offset,
+		"q":           req.Query,
+		"filters":     req.Filters,
+	}
+	if req.Sort != "" {
+		requestBody["sort"] = req.Sort
+	}
+
+	return c.search(c.endpoints.Search, requestBody)
+}
+
+// SearchSQL calls datastore_search_sql. resourceID is sent alongside the
+// statement for portals that expect it and may be empty
+func (c *Client) SearchSQL(resourceID, sql string) (*SearchResult, error) {
+	requestBody := map[string]interface{}{
+		"sql": sql,
+	}
+	if resourceID != "" {
+		requestBody["resource_id"] = resourceID
+	}
+
+	return c.search(c.endpoints.SearchSQL, requestBody)
+}
+
+func (c *Client) search(endpoint string, requestBody map[string]interface{}) (*SearchResult, error) {
+	var raw rawSearchResult
+	if err := c.call(endpoint, requestBody, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Records == nil {
+		return nil, fmt.Errorf("invalid response format: records not found")
+	}
+
+	result := &SearchResult{
+		Records: raw.Records,
+		Total:   -1,
+		Fields:  convertFields(raw.Fields),
+	}
+	if raw.Total != nil {
+		result.Total = *raw.Total
+	}
+	return result, nil
+}
+
+// Info calls datastore_info and returns the fields of a resource
+func (c *Client) Info(resourceID string) ([]models.Field, error) {
+	var raw struct {
+		Fields []rawField `json:"fields"`
+		// Schema maps field names to types on older CKAN versions
+		Schema map[string]json.RawMessage `json:"schema"`
+	}
+	if err := c.call(c.endpoints.Info, map[string]interface{}{"id": resourceID}, &raw); err != nil {
+		return nil, err
+	}
+
+	fields := convertFields(raw.Fields)
+	if len(fields) == 0 {
+		for id, fieldType := range raw.Schema {
+			field := models.Field{ID: id}
+			json.Unmarshal(fieldType, &field.Type)
+			fields = append(fields, field)
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].ID < fields[j].ID })
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid response format: fields not found")
+	}
+	return fields, nil
+}
+
+func convertFields(raw []rawField) []models.Field {
+	var fields []models.Field
+	for _, field := range raw {
+		if field.ID == "" {
+			continue
+		}
+		fields = append(fields, models.Field{ID: field.ID, Type: field.Type, Label: field.Info.Label})
+	}
+	return fields
+}
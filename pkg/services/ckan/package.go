@@ -0,0 +1,44 @@
+package ckan
+
+// Package is a CKAN dataset and its resources
+type Package struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Title     string     `json:"title"`
+	Notes     string     `json:"notes"`
+	License   string     `json:"license_title"`
+	Modified  string     `json:"metadata_modified"`
+	Resources []Resource `json:"resources"`
+}
+
+// Resource is a file or datastore table within a CKAN dataset
+type Resource struct {
+	ID          string `json:"id"`
+	PackageID   string `json:"package_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Format      string `json:"format"`
+	URL         string `json:"url"`
+	Modified    string `json:"last_modified"`
+	// DatastoreActive is true when the resource can be queried with the
+	// datastore actions
+	DatastoreActive bool `json:"datastore_active"`
+}
+
+// PackageShow calls package_show for a dataset id or name
+func (c *Client) PackageShow(id string) (*Package, error) {
+	var pkg Package
+	if err := c.call(c.endpoints.PackageShow, map[string]interface{}{"id": id}, &pkg); err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// ResourceShow calls resource_show for a resource id
+func (c *Client) ResourceShow(id string) (*Resource, error) {
+	var resource Resource
+	if err := c.call(c.endpoints.ResourceShow, map[string]interface{}{"id": id}, &resource); err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
@@ -0,0 +1,150 @@
+package ckan
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+)
+
+// Body logging modes
+const (
+	// BodyLogOff never logs request or response bodies
+	BodyLogOff = "off"
+	// BodyLogTruncate logs bodies at debug level, cut to BodyLogging.Limit
+	// bytes
+	BodyLogTruncate = "truncate"
+	// BodyLogFull logs whole bodies at debug level
+	BodyLogFull = "full"
+)
+
+// redacted replaces every hidden value in logs
+const redacted = "[REDACTED]"
+
+// DefaultRedactFields are the JSON fields whose values are always hidden in
+// logged bodies
+var DefaultRedactFields = []string{
+	"api_key",
+	"apikey",
+	"authorization",
+	"password",
+	"secret",
+	"token",
+}
+
+// BodyLogging controls how request and response bodies are logged
+type BodyLogging struct {
+	// Mode is BodyLogOff, BodyLogTruncate or BodyLogFull
+	Mode string
+	// Limit is the number of bytes logged in BodyLogTruncate mode
+	Limit int
+	// RedactFields are JSON fields, matched ignoring case, whose values are
+	// hidden in addition to DefaultRedactFields
+	RedactFields []string
+}
+
+// Redactor rewrites text before it is logged, to hide sensitive values
+type Redactor func(string) string
+
+// SetBodyLogging sets how request and response bodies are logged. It must be
+// called before the client is used
+func (c *Client) SetBodyLogging(b BodyLogging) error {
+	switch b.Mode {
+	case BodyLogOff, BodyLogTruncate, BodyLogFull:
+	default:
+		return fmt.Errorf("invalid body logging mode %q, valid values are: %s, %s, %s", b.Mode, BodyLogOff, BodyLogTruncate, BodyLogFull)
+	}
+	fields := append(append([]string(nil), DefaultRedactFields...), b.RedactFields...)
+	c.bodyLogging = b
+	c.fieldPattern = fieldPattern(fields)
+	c.paramPattern = paramPattern(fields)
+	return nil
+}
+
+// AddRedactor adds a hook that is applied to everything the client logs,
+// after the API token and redacted fields have been hidden. It must be
+// called before the client is used
+func (c *Client) AddRedactor(r Redactor) {
+	c.redactors = append(c.redactors, r)
+}
+
+// logBody logs a request or response body according to the body logging
+// settings. Every body logged by the client goes through here
+func (c *Client) logBody(msg, endpoint string, body []byte) {
+	if c.bodyLogging.Mode == BodyLogOff || !logger.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+
+	text := c.redact(string(body))
+	truncated := false
+	if c.bodyLogging.Mode == BodyLogTruncate && len(text) > c.bodyLogging.Limit {
+		text, truncated = truncate(text, c.bodyLogging.Limit), true
+	}
+
+	logger.Logger.Debug(msg, "endpoint", endpoint, "bytes", len(body), "truncated", truncated, "body", text)
+}
+
+// redact hides the API token, the values of redacted fields, whether JSON
+// keys or URL query parameters, and anything the redaction hooks match in s
+func (c *Client) redact(s string) string {
+	if c.token != "" {
+		s = strings.ReplaceAll(s, c.token, redacted)
+	}
+	if c.fieldPattern != nil {
+		s = c.fieldPattern.ReplaceAllString(s, `${1}"`+redacted+`"`)
+	}
+	if c.paramPattern != nil {
+		s = c.paramPattern.ReplaceAllString(s, `${1}`+redacted)
+	}
+	for _, r := range c.redactors {
+		s = r(s)
+	}
+	return s
+}
+
+// fieldPattern matches a JSON key from fields, ignoring case, along with
+// its string or scalar value
+func fieldPattern(fields []string) *regexp.Regexp {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = regexp.QuoteMeta(field)
+	}
+	return regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+}
+
+// paramPattern matches a URL query parameter from fields, ignoring case,
+// along with its value
+func paramPattern(fields []string) *regexp.Regexp {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = regexp.QuoteMeta(url.QueryEscape(field))
+	}
+	return regexp.MustCompile(`(?i)([?&](?:` + strings.Join(quoted, "|") + `)=)[^&#\s"]*`)
+}
+
+// truncate cuts s to at most limit bytes without splitting a UTF-8 sequence
+func truncate(s string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	if limit >= len(s) {
+		return s
+	}
+	for limit > 0 && limit < len(s) && s[limit]&0xC0 == 0x80 {
+		limit--
+	}
+	return s[:limit]
+}
+
+// redactURL hides any password in the user information of a URL
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,112 @@
+package ckan
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/logger"
+)
+
+const testToken = "tok-5f2e9c1a"
+
+// testClient returns a client with testToken that also redacts the
+// user-defined field "tfn"
+func testClient(t *testing.T, mode string, limit int) *Client {
+	t.Helper()
+	client := NewClient("https://data.example.gov.au", testToken, DefaultEndpoints("/api/3/action"), nil)
+	if err := client.SetBodyLogging(BodyLogging{Mode: mode, Limit: limit, RedactFields: []string{"tfn"}}); err != nil {
+		t.Fatalf("SetBodyLogging failed: %v", err)
+	}
+	return client
+}
+
+func TestRedact(t *testing.T) {
+	client := testClient(t, BodyLogFull, 0)
+
+	tests := []struct {
+		name   string
+		input  string
+		secret string
+	}{
+		{"token in body", `{"result": {"note": "key tok-5f2e9c1a"}}`, testToken},
+		{"token in URL", "https://data.example.gov.au/api/3/action/datastore_search?key=tok-5f2e9c1a", testToken},
+		{"default field in body", `{"password": "hunter2", "q": "acme"}`, "hunter2"},
+		{"default field in any case", `{"Authorization":"Bearer abc123"}`, "abc123"},
+		{"default field with scalar value", `{"secret": 998877, "q": "acme"}`, "998877"},
+		{"default field with escaped quote", `{"api_key": "ab\"cd9", "q": "acme"}`, `cd9`},
+		{"default field in URL", "https://data.example.gov.au/api/3/action/datastore_search?q=acme&api_key=k-771&limit=5", "k-771"},
+		{"user field in body", `{"records": [{"BN_NAME": "ACME", "tfn": "123456782"}]}`, "123456782"},
+		{"user field in URL", "https://data.example.gov.au/api/3/action/datastore_search?TFN=123456782", "123456782"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := client.redact(tt.input)
+			if strings.Contains(got, tt.secret) {
+				t.Errorf("redact(%s) = %s, still has %q", tt.input, got, tt.secret)
+			}
+			if !strings.Contains(got, redacted) {
+				t.Errorf("redact(%s) = %s, want %s in it", tt.input, got, redacted)
+			}
+		})
+	}
+
+	// Other values and parameters are left alone
+	for _, input := range []string{
+		`{"q": "acme", "limit": 5}`,
+		"https://data.example.gov.au/api/3/action/datastore_search?q=acme&limit=5",
+	} {
+		if got := client.redact(input); got != input {
+			t.Errorf("redact(%s) = %s, want it unchanged", input, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		input string
+		limit int
+		want  string
+	}{
+		{"abcdef", 3, "abc"},
+		{"abc", 10, "abc"},
+		{"abc", 0, ""},
+		// Multi-byte characters are not split
+		{"aé", 2, "a"},
+		{"日本", 4, "日"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.input, tt.limit); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.input, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestLogBodyRedactsBeforeTruncating(t *testing.T) {
+	var logs bytes.Buffer
+	previous := logger.Logger
+	logger.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() { logger.Logger = previous })
+
+	// Each limit cuts the body partway through a secret, which would leave
+	// its start in the log if the body were truncated before redaction
+	body := `{"tfn": "123456782", "token": "tok-5f2e9c1a", "key": "tok-5f2e9c1a"}`
+	tfn := strings.Index(body, "123456782")
+	token := strings.Index(body, testToken)
+	key := strings.LastIndex(body, testToken)
+	for _, limit := range []int{tfn + 6, token + 6, key + 6} {
+		logs.Reset()
+		testClient(t, BodyLogTruncate, limit).logBody("Response body", "/api/3/action/datastore_search", []byte(body))
+
+		out := logs.String()
+		for _, leak := range []string{"1234", "tok-", "5f2e"} {
+			if strings.Contains(out, leak) {
+				t.Errorf("limit %d: log has %q: %s", limit, leak, out)
+			}
+		}
+		if !strings.Contains(out, "truncated=true") {
+			t.Errorf("limit %d: body was not truncated: %s", limit, out)
+		}
+	}
+}
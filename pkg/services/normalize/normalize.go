@@ -0,0 +1,104 @@
+package normalize
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// Query returns the canonical form of a search term: Unicode NFC, lower
+// case, with leading, trailing and repeated whitespace removed. The
+// datastore's full-text search ignores case and extra whitespace, so
+// equivalent terms normalise to the same string
+func Query(query string) string {
+	return strings.ToLower(Text(query))
+}
+
+// Text returns s in Unicode NFC with leading, trailing and repeated
+// whitespace removed, keeping its case
+func Text(s string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(s)), " ")
+}
+
+// State returns the canonical state code, such as "NSW", for s
+func State(state string) (string, error) {
+	state = strings.ToUpper(Text(state))
+	for _, validState := range config.ValidStates {
+		if state == validState {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid state. Valid values are: %s", strings.Join(config.ValidStates, ", "))
+}
+
+// Status returns the canonical registration status, "Registered" or
+// "Deregistered", for s using config.StatusAutoCorrect
+func Status(status string) (string, error) {
+	status = config.StatusAutoCorrect[strings.ToLower(Text(status))]
+	if status != "Registered" && status != "Deregistered" {
+		return "", fmt.Errorf("Invalid registration status. Valid values are: Registered, Deregistered")
+	}
+	return status, nil
+}
+
+// CompanyStatus returns the company register status code, such as "REGD",
+// for s. Registration statuses accepted by Status are mapped to their codes
+func CompanyStatus(status string) (string, error) {
+	code := strings.ToUpper(Text(status))
+	for _, valid := range config.ValidCompanyStatuses {
+		if code == valid {
+			return code, nil
+		}
+	}
+
+	if canonical, err := Status(status); err == nil {
+		return config.CompanyStatusCodes[canonical], nil
+	}
+	return "", fmt.Errorf("Invalid company status. Valid values are: Registered, Deregistered, %s", strings.Join(config.ValidCompanyStatuses, ", "))
+}
+
+// DateRange returns the canonical form of a date range filter, FROM..TO
+// with ISO 8601 dates, for s. Either date may be left out for an open range,
+// and a single date is a range of one day
+func DateRange(s string) (string, error) {
+	s = Text(s)
+	if !strings.Contains(s, models.DateRangeSeparator) {
+		s += models.DateRangeSeparator + s
+	}
+	r, ok := models.ParseDateRange(s)
+	if !ok {
+		return "", fmt.Errorf("Invalid date range %q. Use FROM..TO with dates as YYYY-MM-DD or DD/MM/YYYY, leaving out either date for an open range", s)
+	}
+	return r.String(), nil
+}
+
+// Filters returns a copy of filters with state, status and date range
+// values in canonical form and every other value normalised with Text
+func Filters(filters map[string]string) (map[string]string, error) {
+	result := make(map[string]string, len(filters))
+	for k, v := range filters {
+		var err error
+		switch {
+		case k == "BN_STATE_OF_REG":
+			v, err = State(v)
+		case k == "BN_STATUS":
+			v, err = Status(v)
+		case k == "Status":
+			v, err = CompanyStatus(v)
+		case slices.Contains(config.DateColumns, k):
+			v, err = DateRange(v)
+		default:
+			v = Text(v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[k] = v
+	}
+	return result, nil
+}
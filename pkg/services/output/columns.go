@@ -0,0 +1,88 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// Column is a record field written to the output and its heading
+type Column struct {
+	Field string
+	Title string
+	// Type is the field's datastore type, if the schema has it
+	Type string
+}
+
+// Columns resolves the selected column names, given as field ids or labels,
+// against the dataset schema. Fields that are not in the schema may still
+// be selected by id if the records have them. A selection of
+// config.ColumnsAll is every schema field followed by any other fields found
+// in the records, leaving out CKAN's internal fields
+func Columns(data []map[string]interface{}, names []string, schema *models.Schema) ([]Column, error) {
+	if len(names) == 1 && strings.EqualFold(names[0], config.ColumnsAll) {
+		var columns []Column
+		seen := map[string]bool{}
+		for _, field := range schema.Fields {
+			if strings.HasPrefix(field.ID, "_") {
+				continue
+			}
+			seen[field.ID] = true
+			columns = append(columns, Column{Field: field.ID, Title: title(field), Type: field.Type})
+		}
+
+		var extra []string
+		for _, record := range data {
+			for id := range record {
+				if seen[id] || strings.HasPrefix(id, "_") {
+					continue
+				}
+				seen[id] = true
+				extra = append(extra, id)
+			}
+		}
+		sort.Strings(extra)
+		for _, id := range extra {
+			columns = append(columns, Column{Field: id, Title: title(models.Field{ID: id})})
+		}
+
+		return columns, nil
+	}
+
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		field, ok := schema.Lookup(name)
+		if !ok && !hasField(data, name) {
+			return nil, fmt.Errorf("unknown column %q, run with --list-columns to see the available columns", name)
+		}
+		if !ok {
+			field = models.Field{ID: name}
+		}
+		columns = append(columns, Column{Field: field.ID, Title: title(field), Type: field.Type})
+	}
+	return columns, nil
+}
+
+// title returns the heading for a field: its label, the friendly name from
+// config.HeadersMap, or its id
+func title(field models.Field) string {
+	if field.Label != "" {
+		return field.Label
+	}
+	if name, ok := config.HeadersMap[field.ID]; ok {
+		return name
+	}
+	return field.ID
+}
+
+func hasField(data []map[string]interface{}, id string) bool {
+	for _, record := range data {
+		if _, ok := record[id]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package output
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// htmlPage is a standalone page with the styles and scripts for sorting and
+// filtering embedded, so it can be opened or emailed without other files
+var htmlPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 2rem; color: #1f2328; }
+h1 { font-size: 1.4rem; margin-bottom: 0.25rem; }
+.meta { color: #59636e; font-size: 0.9rem; margin-bottom: 1rem; }
+#filter { padding: 0.4rem 0.6rem; width: 20rem; max-width: 100%; margin-bottom: 1rem; border: 1px solid #d1d9e0; border-radius: 6px; }
+table { border-collapse: collapse; font-size: 0.9rem; }
+th, td { border: 1px solid #d1d9e0; padding: 0.35rem 0.7rem; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; cursor: pointer; user-select: none; white-space: nowrap; position: sticky; top: 0; }
+th:after { content: " \2195"; color: #afb8c1; }
+th[aria-sort="ascending"]:after { content: " \2191"; color: #1f2328; }
+th[aria-sort="descending"]:after { content: " \2193"; color: #1f2328; }
+tbody tr:nth-child(even) { background: #f6f8fa; }
+td.number { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta"><span id="count">{{len .Rows}}</span> of {{len .Rows}} records{{if not .FetchedAt.IsZero}}, fetched {{.FetchedAt.Format "2006-01-02 15:04:05 MST"}}{{if .FromCache}} (from cache){{end}}{{end}}</div>
+<input id="filter" type="search" placeholder="Filter rows" aria-label="Filter rows">
+<table id="results">
+<thead><tr>{{range .Columns}}<th scope="col">{{.Title}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if .Number}} class="number"{{end}}{{if .Sort}} data-sort="{{.Sort}}"{{end}}>{{.Text}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+<script>
+(function () {
+  var table = document.getElementById("results");
+  var body = table.tBodies[0];
+  var filter = document.getElementById("filter");
+  var count = document.getElementById("count");
+
+  function key(cell) {
+    return cell.hasAttribute("data-sort") ? cell.getAttribute("data-sort") : cell.textContent.trim();
+  }
+
+  function compare(a, b) {
+    var x = parseFloat(a), y = parseFloat(b);
+    if (!isNaN(x) && !isNaN(y) && String(x) === a && String(y) === b) {
+      return x - y;
+    }
+    return a.localeCompare(b, undefined, { numeric: true, sensitivity: "base" });
+  }
+
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, index) {
+    th.addEventListener("click", function () {
+      var ascending = th.getAttribute("aria-sort") !== "ascending";
+      Array.prototype.forEach.call(th.parentNode.cells, function (other) { other.removeAttribute("aria-sort"); });
+      th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (r1, r2) {
+        var result = compare(key(r1.cells[index]), key(r2.cells[index]));
+        return ascending ? result : -result;
+      });
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+
+  filter.addEventListener("input", function () {
+    var terms = filter.value.toLowerCase().split(/\s+/).filter(Boolean);
+    var shown = 0;
+    Array.prototype.forEach.call(body.rows, function (row) {
+      var text = row.textContent.toLowerCase();
+      var match = terms.every(function (term) { return text.indexOf(term) !== -1; });
+      row.hidden = !match;
+      if (match) { shown++; }
+    });
+    count.textContent = shown;
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+// htmlCell is a table cell. Sort, when set, is the value the cell sorts by,
+// such as the ISO 8601 form of a date
+type htmlCell struct {
+	Text   string
+	Sort   string
+	Number bool
+}
+
+// writeHTML writes the columns of data as a standalone HTML page holding a
+// table that can be sorted by clicking a heading and filtered by text
+func writeHTML(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	title := "Search results"
+	if meta.Query != "" {
+		title = fmt.Sprintf("Search results for %q", meta.Query)
+	}
+
+	rows := make([][]htmlCell, len(data))
+	for r, record := range data {
+		rows[r] = make([]htmlCell, len(columns))
+		for i, column := range columns {
+			rows[r][i] = htmlValue(record, column)
+		}
+	}
+
+	return htmlPage.Execute(w, struct {
+		Title     string
+		FetchedAt time.Time
+		FromCache bool
+		Columns   []Column
+		Rows      [][]htmlCell
+	}{title, meta.FetchedAt, meta.FromCache, columns, rows})
+}
+
+// htmlValue returns the cell for column in record. Dates sort by their ISO
+// 8601 form and numbers are right-aligned
+func htmlValue(record map[string]interface{}, column Column) htmlCell {
+	value, ok := record[column.Field]
+	if !ok || value == nil {
+		return htmlCell{}
+	}
+
+	cell := htmlCell{Text: columnText(value, column)}
+	switch v := value.(type) {
+	case int, int64, float64:
+		cell.Number = !column.IsIdentifier()
+	case string:
+		if column.IsDate() {
+			if t, parsed := models.ParseDate(v); parsed {
+				cell.Sort = t.Format(time.RFC3339)
+			}
+		}
+	}
+	return cell
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonDocument is the layout of JSONWriter's output. Records are encoded
+// separately so their fields keep the column order
+type jsonDocument struct {
+	Metadata
+	GeneratedAt time.Time         `json:"generated_at"`
+	Count       int               `json:"count"`
+	Columns     []jsonColumn      `json:"columns"`
+	Records     []json.RawMessage `json:"records"`
+}
+
+type jsonColumn struct {
+	Field string `json:"field"`
+	Title string `json:"title"`
+}
+
+// JSONWriter writes the columns of data as a JSON object holding the search
+// metadata, the columns and their titles, and an array of records keyed by
+// field name. Values keep their types and dates are written as ISO 8601.
+// The output goes to filename, or to stdout when filename is empty
+func JSONWriter(data []map[string]interface{}, columns []Column, meta Metadata, filename string) error {
+	return Write(FormatJSON, filename, data, columns, meta)
+}
+
+// NDJSONWriter writes the columns of data as newline-delimited JSON, one
+// record per line, so the output can be processed as it is read. Values are
+// typed as in JSONWriter. The output goes to filename, or to stdout when
+// filename is empty
+func NDJSONWriter(data []map[string]interface{}, columns []Column, filename string) error {
+	return Write(FormatNDJSON, filename, data, columns, Metadata{})
+}
+
+func writeJSON(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	doc := jsonDocument{
+		Metadata:    meta,
+		GeneratedAt: time.Now(),
+		Count:       len(data),
+		Columns:     make([]jsonColumn, len(columns)),
+		Records:     make([]json.RawMessage, len(data)),
+	}
+	for i, column := range columns {
+		doc.Columns[i] = jsonColumn{Field: column.Field, Title: column.Title}
+	}
+	for i, record := range data {
+		encoded, err := jsonRecord(record, columns)
+		if err != nil {
+			return err
+		}
+		doc.Records[i] = encoded
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func writeNDJSON(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	for _, record := range data {
+		encoded, err := jsonRecord(record, columns)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(encoded, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonRecord encodes the columns of record as a JSON object with the fields
+// in column order. Fields the record does not have are null
+func jsonRecord(record map[string]interface{}, columns []Column) (json.RawMessage, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(column.Field)
+		if err != nil {
+			return nil, err
+		}
+		value, _ := typedValue(record, column)
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(encoded)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownEscaper escapes the characters that would break a table cell
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+	"\r", "<br>",
+)
+
+// writeMarkdown writes the columns of data as a GitHub-flavoured Markdown
+// table, headed by the column titles
+func writeMarkdown(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = markdownEscaper.Replace(column.Title)
+	}
+	if err := markdownRow(w, cells); err != nil {
+		return err
+	}
+
+	for i := range columns {
+		cells[i] = "---"
+	}
+	if err := markdownRow(w, cells); err != nil {
+		return err
+	}
+
+	for _, record := range data {
+		for i, column := range columns {
+			cells[i] = ""
+			if value, ok := record[column.Field]; ok && value != nil {
+				cells[i] = markdownEscaper.Replace(columnText(value, column))
+			}
+		}
+		if err := markdownRow(w, cells); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markdownRow(w io.Writer, cells []string) error {
+	_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	return err
+}
@@ -2,27 +2,22 @@ package output
 
 import (
 	"encoding/csv"
-	"fmt"
-	"os"
-	"strings"
-
-	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"io"
 )
 
-func CSVWriter(data []map[string]interface{}, filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+// CSVWriter writes the columns of data as CSV, with the column titles in the
+// first row, to filename or to stdout when filename is empty
+func CSVWriter(data []map[string]interface{}, columns []Column, filename string) error {
+	return Write(FormatCSV, filename, data, columns, Metadata{})
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+func writeCSV(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	writer := csv.NewWriter(w)
 
 	// Write headers using friendly names
-	friendlyHeaders := make([]string, len(config.Headers))
-	for i, header := range config.Headers {
-		friendlyHeaders[i] = config.HeadersMap[header]
+	friendlyHeaders := make([]string, len(columns))
+	for i, column := range columns {
+		friendlyHeaders[i] = column.Title
 	}
 	if err := writer.Write(friendlyHeaders); err != nil {
 		return err
@@ -30,10 +25,10 @@ func CSVWriter(data []map[string]interface{}, filename string) error {
 
 	// Write data
 	for _, record := range data {
-		row := make([]string, len(config.Headers))
-		for i, header := range config.Headers {
-			if value, ok := record[header]; ok {
-				row[i] = fmt.Sprintf("%v", value)
+		row := make([]string, len(columns))
+		for i, column := range columns {
+			if value, ok := record[column.Field]; ok {
+				row[i] = columnText(value, column)
 			}
 		}
 		if err := writer.Write(row); err != nil {
@@ -41,77 +36,13 @@ func CSVWriter(data []map[string]interface{}, filename string) error {
 		}
 	}
 
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
 
-func TerminalTablePrint(data []map[string]interface{}, filename string) error {
-	var writer *os.File
-	var err error
-
-	if filename != "" {
-		writer, err = os.Create(filename)
-		if err != nil {
-			return err
-		}
-		defer writer.Close()
-	} else {
-		writer = os.Stdout
-	}
-
-	// Calculate column widths using friendly headers
-	widths := make([]int, len(config.Headers))
-	for i, header := range config.Headers {
-		friendlyHeader := config.HeadersMap[header]
-		widths[i] = len(friendlyHeader)
-	}
-
-	for _, record := range data {
-		for i, header := range config.Headers {
-			if value, ok := record[header]; ok {
-				valueStr := fmt.Sprintf("%v", value)
-				if len(valueStr) > widths[i] {
-					widths[i] = len(valueStr)
-				}
-			}
-		}
-	}
-
-	// Print headers using friendly names
-	headerLine := ""
-	for i, header := range config.Headers {
-		if i > 0 {
-			headerLine += " | "
-		}
-		friendlyHeader := config.HeadersMap[header]
-		headerLine += fmt.Sprintf("%-*s", widths[i], friendlyHeader)
-	}
-	fmt.Fprintln(writer, headerLine)
-
-	// Print separator
-	separator := ""
-	for i, width := range widths {
-		if i > 0 {
-			separator += "-+-"
-		}
-		separator += strings.Repeat("-", width)
-	}
-	fmt.Fprintln(writer, separator)
-
-	// Print data
-	for _, record := range data {
-		line := ""
-		for i, header := range config.Headers {
-			if i > 0 {
-				line += " | "
-			}
-			value := ""
-			if v, ok := record[header]; ok {
-				value = fmt.Sprintf("%v", v)
-			}
-			line += fmt.Sprintf("%-*s", widths[i], value)
-		}
-		fmt.Fprintln(writer, line)
-	}
-
-	return nil
+// TerminalTablePrint writes the columns of data as a padded text table to
+// filename, or to stdout when filename is empty, with table's style, width
+// and overflow
+func TerminalTablePrint(table TableWriter, data []map[string]interface{}, columns []Column, filename string) error {
+	return WriteTo(table, filename, data, columns, Metadata{})
 }
@@ -0,0 +1,287 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// SQL dialects
+const (
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+const (
+	// DefaultSQLTable is the table SQLWriter creates when none is given
+	DefaultSQLTable = "search_results"
+
+	// DefaultSQLBatchSize is the number of rows in each INSERT statement
+	DefaultSQLBatchSize = 500
+)
+
+// SQLWriter writes records as a SQL script: a CREATE TABLE statement for the
+// columns followed by batched INSERT statements, in a single transaction.
+// Rows are written as they are formatted, so the script is never held in
+// memory
+type SQLWriter struct {
+	// Dialect is DialectPostgres or DialectSQLite. Empty means
+	// DialectPostgres
+	Dialect string
+	// Table is the table to create. Empty means DefaultSQLTable
+	Table string
+	// BatchSize is the number of rows per INSERT. Zero means
+	// DefaultSQLBatchSize
+	BatchSize int
+}
+
+// sqlType is the type of a column in the dump
+type sqlType int
+
+const (
+	sqlText sqlType = iota
+	sqlDate
+	sqlInteger
+	sqlReal
+	sqlBoolean
+)
+
+// ParseDialect checks a dialect name, ignoring case. "postgresql" and "pg"
+// are accepted for DialectPostgres and "sqlite3" for DialectSQLite
+func ParseDialect(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case DialectPostgres, "postgresql", "pg":
+		return DialectPostgres, nil
+	case DialectSQLite, "sqlite3":
+		return DialectSQLite, nil
+	default:
+		return "", fmt.Errorf("invalid SQL dialect %q, valid values are: %s, %s", name, DialectPostgres, DialectSQLite)
+	}
+}
+
+func (s SQLWriter) Write(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	dialect := DialectPostgres
+	if s.Dialect != "" {
+		var err error
+		if dialect, err = ParseDialect(s.Dialect); err != nil {
+			return err
+		}
+	}
+	table := s.Table
+	if table == "" {
+		table = DefaultSQLTable
+	}
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultSQLBatchSize
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns to write")
+	}
+
+	types := make([]sqlType, len(columns))
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		types[i] = columnSQLType(data, column)
+		names[i] = quoteIdentifier(column.Field)
+	}
+
+	fmt.Fprintf(w, "-- %d records", len(data))
+	if meta.Query != "" {
+		fmt.Fprintf(w, " for %s", strings.ReplaceAll(strconv.Quote(meta.Query), "\n", " "))
+	}
+	fmt.Fprintf(w, ", generated %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintln(w, "BEGIN;")
+
+	fmt.Fprintf(w, "CREATE TABLE IF NOT EXISTS %s (\n", quoteIdentifier(table))
+	for i := range columns {
+		separator := ","
+		if i == len(columns)-1 {
+			separator = ""
+		}
+		fmt.Fprintf(w, "  %s %s%s\n", names[i], sqlTypeName(types[i], dialect), separator)
+	}
+	fmt.Fprintln(w, ");")
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES\n", quoteIdentifier(table), strings.Join(names, ", "))
+	var row strings.Builder
+	for start := 0; start < len(data); start += batchSize {
+		end := min(start+batchSize, len(data))
+		if _, err := io.WriteString(w, insert); err != nil {
+			return err
+		}
+		for r := start; r < end; r++ {
+			row.Reset()
+			row.WriteString("  (")
+			for i, column := range columns {
+				if i > 0 {
+					row.WriteString(", ")
+				}
+				row.WriteString(sqlLiteral(data[r][column.Field], types[i], dialect))
+			}
+			row.WriteString(")")
+			if r < end-1 {
+				row.WriteString(",\n")
+			} else {
+				row.WriteString(";\n")
+			}
+			if _, err := io.WriteString(w, row.String()); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintln(w, "COMMIT;")
+	return err
+}
+
+// columnSQLType picks the type of a column from the schema, falling back to
+// the values in data. Dates are DATE and identifiers are always text
+func columnSQLType(data []map[string]interface{}, column Column) sqlType {
+	switch {
+	case column.IsIdentifier():
+		return sqlText
+	case column.IsDate():
+		return sqlDate
+	}
+
+	switch strings.ToLower(column.Type) {
+	case "int", "int2", "int4", "int8", "integer", "bigint", "smallint":
+		return sqlInteger
+	case "numeric", "float", "float4", "float8", "real", "double precision":
+		return sqlReal
+	case "bool", "boolean":
+		return sqlBoolean
+	case "":
+	default:
+		return sqlText
+	}
+
+	// With no schema type, use the narrowest type every value fits
+	result, seen := sqlInteger, false
+	for _, record := range data {
+		switch record[column.Field].(type) {
+		case nil:
+			continue
+		case int, int64:
+			if result == sqlBoolean {
+				return sqlText
+			}
+		case float64:
+			if result == sqlBoolean {
+				return sqlText
+			}
+			result = sqlReal
+		case bool:
+			if seen && result != sqlBoolean {
+				return sqlText
+			}
+			result = sqlBoolean
+		default:
+			return sqlText
+		}
+		seen = true
+	}
+	if !seen {
+		return sqlText
+	}
+	return result
+}
+
+// sqlTypeName returns the name of a column type in dialect
+func sqlTypeName(t sqlType, dialect string) string {
+	switch t {
+	case sqlDate:
+		return "DATE"
+	case sqlInteger:
+		if dialect == DialectSQLite {
+			return "INTEGER"
+		}
+		return "BIGINT"
+	case sqlReal:
+		if dialect == DialectSQLite {
+			return "REAL"
+		}
+		return "DOUBLE PRECISION"
+	case sqlBoolean:
+		if dialect == DialectSQLite {
+			return "INTEGER"
+		}
+		return "BOOLEAN"
+	default:
+		return "TEXT"
+	}
+}
+
+// sqlLiteral formats a value for a column of type t. Missing values, blank
+// dates and values that do not fit the column's type are NULL
+func sqlLiteral(value interface{}, t sqlType, dialect string) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	switch t {
+	case sqlDate:
+		s, ok := value.(string)
+		if !ok {
+			return "NULL"
+		}
+		date, ok := models.ParseDate(s)
+		if !ok {
+			return "NULL"
+		}
+		return quoteString(isoDate(date))
+	case sqlInteger, sqlReal:
+		switch v := value.(type) {
+		case int:
+			return strconv.Itoa(v)
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return "NULL"
+			}
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) {
+				return strconv.FormatFloat(n, 'g', -1, 64)
+			}
+		}
+		return "NULL"
+	case sqlBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return "NULL"
+		}
+		switch {
+		case dialect == DialectSQLite && b:
+			return "1"
+		case dialect == DialectSQLite:
+			return "0"
+		case b:
+			return "TRUE"
+		default:
+			return "FALSE"
+		}
+	default:
+		return quoteString(textValue(value))
+	}
+}
+
+// quoteString returns s as a SQL string literal. NUL characters, which
+// neither dialect stores in text, are removed
+func quoteString(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// quoteIdentifier returns name as a quoted SQL identifier, so field names
+// with spaces or mixed case can be used as column names
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,341 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+	"golang.org/x/term"
+)
+
+// Table styles
+const (
+	// TableStyleClassic separates columns with " | " and the header with
+	// dashes, with no outer border
+	TableStyleClassic = "classic"
+	TableStyleASCII   = "ascii"
+	TableStyleLight   = "light"
+	TableStyleRounded = "rounded"
+	TableStyleHeavy   = "heavy"
+	TableStyleDouble  = "double"
+)
+
+// Ways of fitting cells that are too wide for their column
+const (
+	// OverflowTruncate cuts long cells short and ends them with an ellipsis
+	OverflowTruncate = "truncate"
+	// OverflowWrap breaks long cells over several lines
+	OverflowWrap = "wrap"
+	// OverflowNone keeps every cell whole, however wide the table becomes
+	OverflowNone = "none"
+)
+
+// minColumnWidth is the narrowest a column is made to fit the table width
+const minColumnWidth = 5
+
+// tableStyle holds the characters a table is drawn with. Each rule is the
+// left edge, the horizontal line, the crossing between columns and the
+// right edge
+type tableStyle struct {
+	top, header, bottom [4]string
+	vertical            string
+	// border draws the outer edges and the top and bottom rules
+	border bool
+}
+
+var tableStyles = map[string]tableStyle{
+	TableStyleClassic: {header: [4]string{"", "-", "-+-", ""}, vertical: "|"},
+	TableStyleASCII: {
+		top:      [4]string{"+", "-", "+", "+"},
+		header:   [4]string{"+", "-", "+", "+"},
+		bottom:   [4]string{"+", "-", "+", "+"},
+		vertical: "|",
+		border:   true,
+	},
+	TableStyleLight: {
+		top:      [4]string{"┌", "─", "┬", "┐"},
+		header:   [4]string{"├", "─", "┼", "┤"},
+		bottom:   [4]string{"└", "─", "┴", "┘"},
+		vertical: "│",
+		border:   true,
+	},
+	TableStyleRounded: {
+		top:      [4]string{"╭", "─", "┬", "╮"},
+		header:   [4]string{"├", "─", "┼", "┤"},
+		bottom:   [4]string{"╰", "─", "┴", "╯"},
+		vertical: "│",
+		border:   true,
+	},
+	TableStyleHeavy: {
+		top:      [4]string{"┏", "━", "┳", "┓"},
+		header:   [4]string{"┣", "━", "╋", "┫"},
+		bottom:   [4]string{"┗", "━", "┻", "┛"},
+		vertical: "┃",
+		border:   true,
+	},
+	TableStyleDouble: {
+		top:      [4]string{"╔", "═", "╦", "╗"},
+		header:   [4]string{"╠", "═", "╬", "╣"},
+		bottom:   [4]string{"╚", "═", "╩", "╝"},
+		vertical: "║",
+		border:   true,
+	},
+}
+
+// TableStyles returns the names of the table styles
+func TableStyles() []string {
+	return []string{TableStyleClassic, TableStyleASCII, TableStyleLight, TableStyleRounded, TableStyleHeavy, TableStyleDouble}
+}
+
+// TableWriter writes records as a text table aligned by display width, so
+// wide and combining characters line up
+type TableWriter struct {
+	// Style is one of TableStyles. Empty means TableStyleClassic
+	Style string
+	// Width is the widest the table may be, such as the terminal width. Zero
+	// means no limit
+	Width int
+	// Overflow is how cells are fitted when the table is wider than Width:
+	// OverflowTruncate, OverflowWrap or OverflowNone. Empty means
+	// OverflowTruncate
+	Overflow string
+}
+
+// ParseTableStyle checks a table style name, ignoring case
+func ParseTableStyle(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := tableStyles[name]; !ok {
+		return "", fmt.Errorf("invalid table style %q, valid values are: %s", name, strings.Join(TableStyles(), ", "))
+	}
+	return name, nil
+}
+
+// ParseOverflow checks an overflow mode, ignoring case
+func ParseOverflow(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case OverflowTruncate, OverflowWrap, OverflowNone:
+		return name, nil
+	}
+	return "", fmt.Errorf("invalid overflow %q, valid values are: %s, %s, %s", name, OverflowTruncate, OverflowWrap, OverflowNone)
+}
+
+// TerminalWidth returns the width of the terminal stdout is connected to,
+// or $COLUMNS, or 0 when stdout is not a terminal
+func TerminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	if !term.IsTerminal(fd) {
+		return 0
+	}
+	if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 0
+}
+
+func (t TableWriter) Write(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	styleName := TableStyleClassic
+	if t.Style != "" {
+		var err error
+		if styleName, err = ParseTableStyle(t.Style); err != nil {
+			return err
+		}
+	}
+	style := tableStyles[styleName]
+	overflow := OverflowTruncate
+	if t.Overflow != "" {
+		var err error
+		if overflow, err = ParseOverflow(t.Overflow); err != nil {
+			return err
+		}
+	}
+
+	// Lay out the cells, measuring columns by display width
+	header := make([]string, len(columns))
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		header[i] = cellText(column.Title)
+		widths[i] = runewidth.StringWidth(header[i])
+	}
+	rows := make([][]string, len(data))
+	for r, record := range data {
+		rows[r] = make([]string, len(columns))
+		for i, column := range columns {
+			if value, ok := record[column.Field]; ok && value != nil {
+				rows[r][i] = cellText(columnText(value, column))
+			}
+			widths[i] = max(widths[i], runewidth.StringWidth(rows[r][i]))
+		}
+	}
+
+	if t.Width > 0 && overflow != OverflowNone {
+		fitWidths(widths, t.Width-style.overhead(len(columns)))
+	}
+
+	if style.border {
+		if err := style.rule(w, style.top, widths); err != nil {
+			return err
+		}
+	}
+	if err := style.row(w, header, widths, overflow); err != nil {
+		return err
+	}
+	if err := style.rule(w, style.header, widths); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := style.row(w, row, widths, overflow); err != nil {
+			return err
+		}
+	}
+	if style.border {
+		return style.rule(w, style.bottom, widths)
+	}
+	return nil
+}
+
+// overhead returns the width taken by separators and borders in a table of
+// n columns
+func (s tableStyle) overhead(n int) int {
+	if s.border {
+		return 3*n + 1
+	}
+	return 3 * (n - 1)
+}
+
+// rule writes a horizontal line across the columns
+func (s tableStyle) rule(w io.Writer, chars [4]string, widths []int) error {
+	var b strings.Builder
+	for i, width := range widths {
+		if i == 0 {
+			b.WriteString(chars[0])
+		} else {
+			b.WriteString(chars[2])
+		}
+		if s.border {
+			width += 2
+		}
+		b.WriteString(strings.Repeat(chars[1], width))
+	}
+	b.WriteString(chars[3])
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// row writes one row of cells, over several lines when cells wrap
+func (s tableStyle) row(w io.Writer, cells []string, widths []int, overflow string) error {
+	lines := make([][]string, len(cells))
+	height := 1
+	for i, cell := range cells {
+		switch {
+		case runewidth.StringWidth(cell) <= widths[i]:
+			lines[i] = []string{cell}
+		case overflow == OverflowWrap:
+			lines[i] = wrapCell(cell, widths[i])
+		default:
+			lines[i] = []string{runewidth.Truncate(cell, widths[i], "…")}
+		}
+		height = max(height, len(lines[i]))
+	}
+
+	separator := " " + s.vertical + " "
+	for l := 0; l < height; l++ {
+		var b strings.Builder
+		if s.border {
+			b.WriteString(s.vertical + " ")
+		}
+		for i := range cells {
+			if i > 0 {
+				b.WriteString(separator)
+			}
+			text := ""
+			if l < len(lines[i]) {
+				text = lines[i][l]
+			}
+			b.WriteString(runewidth.FillRight(text, widths[i]))
+		}
+		if s.border {
+			b.WriteString(" " + s.vertical)
+		}
+		b.WriteByte('\n')
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fitWidths narrows the widest columns, one character at a time, until they
+// fit in available, without making any narrower than minColumnWidth
+func fitWidths(widths []int, available int) {
+	total := 0
+	for _, width := range widths {
+		total += width
+	}
+
+	for total > available {
+		widest := 0
+		for i, width := range widths {
+			if width > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			return
+		}
+		widths[widest]--
+		total--
+	}
+}
+
+// wrapCell breaks s into lines no wider than width, between words where it
+// can and inside words that are wider than a line
+func wrapCell(s string, width int) []string {
+	var lines []string
+	line, lineWidth := "", 0
+	for _, word := range strings.Fields(s) {
+		wordWidth := runewidth.StringWidth(word)
+		switch {
+		case line != "" && lineWidth+1+wordWidth <= width:
+			line += " " + word
+			lineWidth += 1 + wordWidth
+			continue
+		case line != "":
+			lines = append(lines, line)
+		}
+
+		// Split words that do not fit on a line of their own
+		line, lineWidth = "", 0
+		for _, r := range word {
+			rw := runewidth.RuneWidth(r)
+			if lineWidth+rw > width && line != "" {
+				lines = append(lines, line)
+				line, lineWidth = "", 0
+			}
+			line += string(r)
+			lineWidth += rw
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// cellText replaces line breaks, tabs and other control characters with
+// spaces so a value stays on its row
+func cellText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, s)
+}
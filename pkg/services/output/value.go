@@ -0,0 +1,79 @@
+package output
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/config"
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// IsDate reports whether the column holds dates, either because the schema
+// says so or because it is one of config.DateColumns
+func (c Column) IsDate() bool {
+	switch strings.ToLower(c.Type) {
+	case "date", "timestamp", "timestamptz":
+		return true
+	}
+	return slices.Contains(config.DateColumns, c.Field)
+}
+
+// IsIdentifier reports whether the column holds identifiers, such as ABNs,
+// that must be kept as text
+func (c Column) IsIdentifier() bool {
+	return slices.Contains(config.IdentifierColumns, c.Field)
+}
+
+// isoDate formats t as an ISO 8601 date, with the time only when it has one
+func isoDate(t time.Time) string {
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// typedValue returns the value of column in record for typed output formats.
+// Numbers and booleans are kept as they are, identifiers are always strings
+// and dates are changed to ISO 8601. ok is false when the record does not
+// have the field
+func typedValue(record map[string]interface{}, column Column) (value interface{}, ok bool) {
+	value, ok = record[column.Field]
+	if !ok || value == nil {
+		return nil, ok
+	}
+
+	if column.IsIdentifier() {
+		return textValue(value), true
+	}
+
+	if s, isString := value.(string); isString && column.IsDate() {
+		if strings.TrimSpace(s) == "" {
+			return nil, true
+		}
+		if t, parsed := models.ParseDate(s); parsed {
+			return isoDate(t), true
+		}
+	}
+	return value, true
+}
+
+// columnText formats the value of column as text. Identifiers are written
+// in full, as by textValue
+func columnText(value interface{}, column Column) string {
+	if column.IsIdentifier() {
+		return textValue(value)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// textValue formats value as text. Floats are written in full rather than
+// in exponent form, so identifiers decoded from JSON keep every digit
+func textValue(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
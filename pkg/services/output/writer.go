@@ -0,0 +1,202 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// Built-in output formats
+const (
+	FormatTable    = "table"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatXLSX     = "xlsx"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatSQL      = "sql"
+)
+
+// Stdout is the filename that writes to standard output
+const Stdout = "-"
+
+// Metadata describes the search that produced the records being written.
+// Formats that have nowhere to put it ignore it
+type Metadata struct {
+	Query      string    `json:"query"`
+	ResourceID string    `json:"resource_id"`
+	FetchedAt  time.Time `json:"fetched_at"`
+	FromCache  bool      `json:"from_cache"`
+	Stale      bool      `json:"stale"`
+	// Stats are summary statistics for formats that can include them
+	Stats *models.BusinessStats `json:"-"`
+}
+
+// Writer writes the columns of search results in one format
+type Writer interface {
+	Write(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error
+}
+
+// WriterFunc adapts a function to the Writer interface
+type WriterFunc func(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error
+
+func (f WriterFunc) Write(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	return f(w, data, columns, meta)
+}
+
+// format is a registered Writer and the file extensions that select it
+type format struct {
+	writer     Writer
+	extensions []string
+}
+
+// builtins are the formats Register cannot replace. Writers with other
+// options, such as a SQLWriter for another dialect, are passed to WriteTo
+var builtins = map[string]format{
+	FormatTable:    {TableWriter{}, []string{".txt"}},
+	FormatCSV:      {WriterFunc(writeCSV), []string{".csv"}},
+	FormatJSON:     {WriterFunc(writeJSON), []string{".json"}},
+	FormatNDJSON:   {WriterFunc(writeNDJSON), []string{".ndjson", ".jsonl"}},
+	FormatXLSX:     {WriterFunc(writeXLSX), []string{".xlsx"}},
+	FormatMarkdown: {WriterFunc(writeMarkdown), []string{".md", ".markdown"}},
+	FormatHTML:     {WriterFunc(writeHTML), []string{".html", ".htm"}},
+	FormatSQL:      {SQLWriter{}, []string{".sql"}},
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = maps.Clone(builtins)
+)
+
+// Register adds a format, replacing any format with the same name other
+// than a built-in one. Files with one of the extensions, such as ".csv",
+// are written in this format unless another is chosen
+func Register(name string, writer Writer, extensions ...string) error {
+	name = strings.ToLower(name)
+	if _, ok := builtins[name]; ok {
+		return fmt.Errorf("format %q is built in and cannot be replaced", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = format{writer: writer, extensions: extensions}
+	return nil
+}
+
+// Lookup returns the writer for a format name, ignoring case
+func Lookup(name string) (Writer, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("invalid format %q, valid values are: %s", name, strings.Join(formatNames(), ", "))
+	}
+	return f.writer, nil
+}
+
+// Formats returns the names of the registered formats, sorted
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return formatNames()
+}
+
+// FormatForFile returns the format selected by filename's extension, or ""
+// if no format uses it
+func FormatForFile(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, name := range formatNames() {
+		for _, e := range registry[name].extensions {
+			if strings.EqualFold(e, ext) {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// ResolveFormat returns the format Write uses for formatName and filename:
+// formatName, or when it is empty the format for the file's extension,
+// falling back to FormatTable
+func ResolveFormat(formatName, filename string) string {
+	if formatName == "" {
+		formatName = FormatForFile(filename)
+	}
+	if formatName == "" {
+		formatName = FormatTable
+	}
+	return strings.ToLower(formatName)
+}
+
+// Write writes the columns of data in the named format to filename, or to
+// stdout when filename is empty or Stdout. The format is chosen by
+// ResolveFormat and written with its default options
+func Write(formatName, filename string, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	writer, err := Lookup(ResolveFormat(formatName, filename))
+	if err != nil {
+		return err
+	}
+	return WriteTo(writer, filename, data, columns, meta)
+}
+
+// WriteTo writes the columns of data with writer to filename, or to stdout
+// when filename is empty or Stdout. Use it for writers with options of
+// their own, such as a SQLWriter for another dialect
+func WriteTo(writer Writer, filename string, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	return writeTo(filename, func(w io.Writer) error {
+		return writer.Write(w, data, columns, meta)
+	})
+}
+
+// formatNames returns the registered format names, sorted. The caller must
+// hold registryMu
+func formatNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeTo calls write with a buffered writer for filename, or for stdout
+// when filename is empty or Stdout, and flushes it
+func writeTo(filename string, write func(io.Writer) error) error {
+	out := os.Stdout
+	if filename != "" && filename != Stdout {
+		file, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	w := bufio.NewWriter(out)
+	if err := write(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if out != os.Stdout {
+		return out.Close()
+	}
+	return nil
+}
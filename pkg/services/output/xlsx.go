@@ -0,0 +1,364 @@
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mohnish226/australian-business-data-api/pkg/services/api/models"
+)
+
+// Cell styles, indexes into cellXfs in xlsxStyles
+const (
+	styleDefault = iota
+	styleHeader
+	styleDate
+	styleText
+	styleDecimal
+)
+
+const (
+	// resultsSheet and summarySheet are the worksheet names
+	resultsSheet = "Results"
+	summarySheet = "Summary"
+
+	// maxColumnWidth caps the width of a column, in characters
+	maxColumnWidth = 60
+)
+
+// excelEpoch is day zero of Excel's date serial numbers
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`%s</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>` +
+	`<fonts count="2">` +
+	`<font><sz val="11"/><name val="Calibri"/><family val="2"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/><family val="2"/></font>` +
+	`</fonts>` +
+	`<fills count="3">` +
+	`<fill><patternFill patternType="none"/></fill>` +
+	`<fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill>` +
+	`</fills>` +
+	`<borders count="2">` +
+	`<border><left/><right/><top/><bottom/><diagonal/></border>` +
+	`<border><left/><right/><top/><bottom style="thin"><color auto="1"/></bottom><diagonal/></border>` +
+	`</borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="5">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="1" xfId="0" applyFont="1" applyFill="1" applyBorder="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="49" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// xlsxCell is one cell of a worksheet row
+type xlsxCell struct {
+	value interface{}
+	style int
+}
+
+// xlsxSheet is a worksheet to be written to the workbook
+type xlsxSheet struct {
+	name   string
+	rows   [][]xlsxCell
+	widths []int
+	// header freezes the first row and adds an auto-filter over the rows
+	header bool
+}
+
+// writeXLSX writes the columns of data as an Excel workbook. Dates become
+// date cells, identifiers such as ABNs are kept as text, and the header row
+// is styled, frozen and filterable. When meta has Stats they are added on a
+// second sheet
+func writeXLSX(w io.Writer, data []map[string]interface{}, columns []Column, meta Metadata) error {
+	sheets := []*xlsxSheet{resultsXLSXSheet(data, columns)}
+	if meta.Stats != nil {
+		sheets = append(sheets, summaryXLSXSheet(meta.Stats))
+	}
+
+	var overrides, sheetList, sheetRels bytes.Buffer
+	for i, sheet := range sheets {
+		fmt.Fprintf(&overrides, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+		fmt.Fprintf(&sheetList, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.name), i+1, i+1)
+		fmt.Fprintf(&sheetRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&sheetRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+
+	var definedNames string
+	if results := sheets[0]; len(columns) > 0 {
+		definedNames = fmt.Sprintf(`<definedNames><definedName name="_xlnm._FilterDatabase" localSheetId="0" hidden="1">%s!$A$1:$%s$%d</definedName></definedNames>`,
+			results.name, columnName(len(columns)-1), len(results.rows))
+	}
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, overrides.String())},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets>` + sheetList.String() + `</sheets>` + definedNames + `</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + sheetRels.String() + `</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return err
+		}
+	}
+	for i, sheet := range sheets {
+		f, err := archive.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := sheet.write(f, i == 0); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// resultsXLSXSheet lays out the records as a worksheet with a header row
+func resultsXLSXSheet(data []map[string]interface{}, columns []Column) *xlsxSheet {
+	sheet := &xlsxSheet{name: resultsSheet, header: true}
+
+	header := make([]xlsxCell, len(columns))
+	for i, column := range columns {
+		header[i] = xlsxCell{column.Title, styleHeader}
+	}
+	sheet.add(header)
+
+	for _, record := range data {
+		row := make([]xlsxCell, len(columns))
+		for i, column := range columns {
+			row[i] = xlsxValue(record, column)
+		}
+		sheet.add(row)
+	}
+	return sheet
+}
+
+// summaryXLSXSheet lays out the summary statistics as a worksheet of
+// labelled values and count tables
+func summaryXLSXSheet(stats *models.BusinessStats) *xlsxSheet {
+	sheet := &xlsxSheet{name: summarySheet}
+	sheet.add([]xlsxCell{{"Statistic", styleHeader}, {"Value", styleHeader}})
+	sheet.add([]xlsxCell{{"Records", styleDefault}, {stats.TotalCount, styleDefault}})
+	sheet.add([]xlsxCell{{"Registered", styleDefault}, {stats.ActiveCount, styleDefault}})
+	sheet.add([]xlsxCell{{"Average age (years)", styleDefault}, {stats.AverageAge, styleDecimal}})
+
+	tables := []struct {
+		title  string
+		counts map[string]int
+	}{
+		{"Status", stats.StatusDistribution},
+		{"State", stats.StateDistribution},
+		{"Year of Registration", stats.RegistrationByYear},
+	}
+	for _, table := range tables {
+		if len(table.counts) == 0 {
+			continue
+		}
+		sheet.add(nil)
+		sheet.add([]xlsxCell{{table.title, styleHeader}, {"Count", styleHeader}})
+
+		keys := make([]string, 0, len(table.counts))
+		for key := range table.counts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			sheet.add([]xlsxCell{{key, styleText}, {table.counts[key], styleDefault}})
+		}
+	}
+	return sheet
+}
+
+// xlsxValue returns the cell for column in record. Dates become date serial
+// numbers and identifiers are written as text
+func xlsxValue(record map[string]interface{}, column Column) xlsxCell {
+	value, ok := record[column.Field]
+	if !ok || value == nil {
+		return xlsxCell{}
+	}
+	if s, isString := value.(string); isString && strings.TrimSpace(s) == "" {
+		return xlsxCell{}
+	}
+
+	if column.IsIdentifier() {
+		return xlsxCell{textValue(value), styleText}
+	}
+	if s, isString := value.(string); isString && column.IsDate() {
+		if t, parsed := models.ParseDate(s); parsed {
+			return xlsxCell{t, styleDate}
+		}
+	}
+	return xlsxCell{value, styleDefault}
+}
+
+// add appends a row to the sheet and widens its columns to fit
+func (s *xlsxSheet) add(row []xlsxCell) {
+	s.rows = append(s.rows, row)
+	for i, cell := range row {
+		width := 10
+		switch v := cell.value.(type) {
+		case nil:
+		case string:
+			width = utf8.RuneCountInString(v)
+		case time.Time:
+		default:
+			width = len(fmt.Sprintf("%v", v))
+		}
+		if cell.style == styleHeader {
+			width += 3 // room for the filter button
+		}
+		for len(s.widths) <= i {
+			s.widths = append(s.widths, 0)
+		}
+		if width > s.widths[i] {
+			s.widths[i] = min(width, maxColumnWidth)
+		}
+	}
+}
+
+// write writes the sheet's worksheet XML. selected makes it the active tab
+func (s *xlsxSheet) write(w io.Writer, selected bool) error {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"`)
+	if selected {
+		b.WriteString(` tabSelected="1"`)
+	}
+	b.WriteString(`>`)
+	if s.header {
+		b.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/><selection pane="bottomLeft" activeCell="A2" sqref="A2"/>`)
+	}
+	b.WriteString(`</sheetView></sheetViews>`)
+
+	if len(s.widths) > 0 {
+		b.WriteString(`<cols>`)
+		for i, width := range s.widths {
+			fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, width+2)
+		}
+		b.WriteString(`</cols>`)
+	}
+
+	b.WriteString(`<sheetData>`)
+	for r, row := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			writeXLSXCell(&b, fmt.Sprintf("%s%d", columnName(c), r+1), cell)
+		}
+		b.WriteString(`</row>`)
+
+		// Flush large sheets as they are built
+		if b.Len() > 64*1024 {
+			if _, err := w.Write(b.Bytes()); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+	}
+	b.WriteString(`</sheetData>`)
+
+	if s.header && len(s.widths) > 0 {
+		fmt.Fprintf(&b, `<autoFilter ref="A1:%s%d"/>`, columnName(len(s.widths)-1), len(s.rows))
+	}
+	b.WriteString(`</worksheet>`)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeXLSXCell writes one cell. Strings are written inline so no shared
+// string table is needed
+func writeXLSXCell(b *bytes.Buffer, ref string, cell xlsxCell) {
+	style := ""
+	if cell.style != styleDefault {
+		style = fmt.Sprintf(` s="%d"`, cell.style)
+	}
+
+	switch v := cell.value.(type) {
+	case nil:
+		if style != "" {
+			fmt.Fprintf(b, `<c r="%s"%s/>`, ref, style)
+		}
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		fmt.Fprintf(b, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, style, n)
+	case int:
+		fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+	case int64:
+		fmt.Fprintf(b, `<c r="%s"%s><v>%d</v></c>`, ref, style, v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			writeXLSXCell(b, ref, xlsxCell{strconv.FormatFloat(v, 'g', -1, 64), cell.style})
+			return
+		}
+		fmt.Fprintf(b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+	case time.Time:
+		serial := v.Sub(excelEpoch).Hours() / 24
+		fmt.Fprintf(b, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(serial, 'f', -1, 64))
+	case string:
+		fmt.Fprintf(b, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(v))
+	default:
+		writeXLSXCell(b, ref, xlsxCell{fmt.Sprintf("%v", v), cell.style})
+	}
+}
+
+// columnName returns the spreadsheet name of a 0-based column: A, B, ...,
+// Z, AA, AB, ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xmlEscape escapes s for use in XML text or attributes, replacing
+// characters XML cannot hold
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}